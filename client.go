@@ -0,0 +1,49 @@
+package sftps
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Client lists the common operations of the Sftps so that the code using them can be given a fake in its tests.
+// The *Sftps satisfies it, the less common operations are reached through the *Sftps itself.
+type Client interface {
+	Connect() (res []*FtpResponse, err error)
+	ConnectContext(ctx context.Context) (res []*FtpResponse, err error)
+	Reconnect() (res []*FtpResponse, err error)
+	Quit() (res *FtpResponse, err error)
+
+	List(baseDir string) (res []*FtpResponse, list string, err error)
+	ListFileInfo(p string) (list []os.FileInfo, err error)
+	ReadDir(p string) (list []os.FileInfo, err error)
+	Walk(root string, fn filepath.WalkFunc) (err error)
+	Glob(pattern string) (matches []string, err error)
+
+	Mkdir(p string) (res []*FtpResponse, err error)
+	MkdirAll(p string) (err error)
+	Rmdir(p string) (res []*FtpResponse, err error)
+	RemoveAll(p string) (err error)
+	Rename(old string, new string) (res []*FtpResponse, err error)
+	Symlink(oldname string, newname string) (err error)
+	ReadLink(p string) (target string, err error)
+
+	Stat(p string) (info os.FileInfo, err error)
+	Lstat(p string) (info os.FileInfo, err error)
+	Exists(p string) (ok bool, err error)
+	Chmod(p string, mode os.FileMode) (err error)
+	Chtimes(p string, atime time.Time, mtime time.Time) (err error)
+
+	Upload(local interface{}, remote string) (res []*FtpResponse, len int64, err error)
+	Download(local interface{}, remote string) (res []*FtpResponse, len int64, err error)
+	UploadContext(ctx context.Context, local interface{}, remote string) (len int64, err error)
+	DownloadContext(ctx context.Context, local interface{}, remote string) (len int64, err error)
+	UploadFrom(r io.Reader, remote string) (len int64, err error)
+	DownloadTo(w io.Writer, remote string) (len int64, err error)
+	ReadFile(remote string) (data []byte, err error)
+	WriteFile(remote string, data []byte, mode os.FileMode) (err error)
+}
+
+var _ Client = (*Sftps)(nil)
@@ -0,0 +1,166 @@
+package sftps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/sftp"
+)
+
+// TransferPair is the local file and the remote path of a transfer.
+type TransferPair struct {
+	Local  string
+	Remote string
+}
+
+// TransferResult reports the outcome of the transfer of a TransferPair.
+type TransferResult struct {
+	TransferPair
+	Len int64
+	Err error
+}
+
+// uploadFiles uploads the pairs in parallel with at most the concurrency workers sharing the connection.
+// The results are in the order of the pairs, the err reports how many of them failed.
+func (this *SecureFtp) uploadFiles(pairs []TransferPair, concurrency int) (results []TransferResult, err error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results = make([]TransferResult, len(pairs))
+	jobs := make(chan int)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < concurrency && i < len(pairs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := &results[j]
+				res.TransferPair = pairs[j]
+				res.Len, res.Err = this.upload(res.Local, res.Remote, nil)
+			}
+		}()
+	}
+	for i := range pairs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d uploads failed.", failed, len(pairs))
+	}
+	return
+}
+
+// downloadConcurrent splits the remote file into the chunks, each of them is read by its own reader
+// and written at its offset of the local file. The chunks share the rate limit.
+func (this *SecureFtp) downloadConcurrent(remote string, local string, chunks int) (len int64, err error) {
+	remote = this.resolve(remote)
+	var info os.FileInfo
+	if info, err = this.sftpClient.Stat(remote); err != nil {
+		err = fmt.Errorf(`Download "%v": %w`, remote, err)
+		return
+	}
+	size := info.Size()
+	if chunks <= 1 || size < int64(chunks) {
+		return this.get(local, remote)
+	}
+
+	var w *os.File
+	if w, err = os.Create(local); err != nil {
+		return
+	}
+	if err = w.Truncate(size); err != nil {
+		w.Close()
+		os.Remove(local)
+		return
+	}
+
+	chunkSize := size / int64(chunks)
+	lens := make([]int64, chunks)
+	errs := make([]error, chunks)
+	limiter := this.newRateLimiter()
+	wg := new(sync.WaitGroup)
+	for i := 0; i < chunks; i++ {
+		off := int64(i) * chunkSize
+		n := chunkSize
+		if i == chunks-1 {
+			// The final chunk takes the remainder.
+			n = size - off
+		}
+		wg.Add(1)
+		go func(i int, off int64, n int64) {
+			defer wg.Done()
+			var r *sftp.File
+			if r, errs[i] = this.sftpClient.Open(remote); errs[i] != nil {
+				return
+			}
+			defer r.Close()
+			lens[i], errs[i] = this.copyLimited(context.Background(), io.NewOffsetWriter(w, off), io.NewSectionReader(r, off, n), r, limiter)
+			if errs[i] == nil && lens[i] != n {
+				errs[i] = fmt.Errorf(`Download "%v": the chunk at %d is short, %d of %d bytes.`, remote, off, lens[i], n)
+			}
+		}(i, off, n)
+	}
+	wg.Wait()
+
+	err = w.Close()
+	var chunkErr error
+	for i := range lens {
+		len += lens[i]
+		if errs[i] != nil && chunkErr == nil {
+			chunkErr = errs[i]
+		}
+	}
+	if chunkErr != nil {
+		err = chunkErr
+	}
+	// The chunks leave the holes of the zeros, the failed file can not be resumed and is removed.
+	if err != nil {
+		os.Remove(local)
+	}
+	return
+}
+
+// statMany stats the paths in parallel with at most the concurrency workers sharing the connection,
+// the failure of a path is collected into the errs without aborting the others.
+func (this *SecureFtp) statMany(paths []string, concurrency int) (infos map[string]os.FileInfo, errs []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	infos = make(map[string]os.FileInfo, len(paths))
+	mu := new(sync.Mutex)
+	jobs := make(chan string)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < concurrency && i < len(paths); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				info, err := this.stat(p)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					infos[p] = info
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range paths {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	return
+}
@@ -0,0 +1,36 @@
+package sftps
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadConcurrent(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	// The size is not a multiple of the chunks, the final chunk is ragged.
+	data := make([]byte, 1<<20+3)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(dir, "remote.bin")
+	if err := os.WriteFile(remote, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(dir, "local.bin")
+	n, err := client.DownloadConcurrent(filepath.ToSlash(remote), local, 4)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("DownloadConcurrent = %d, %v", n, err)
+	}
+	if got, err := os.ReadFile(local); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("the reassembled file differs, %v", err)
+	}
+	missing := filepath.ToSlash(filepath.Join(dir, "missing.bin"))
+	if _, err = client.DownloadConcurrent(missing, local, 4); !IsNotFound(err) || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("DownloadConcurrent of the missing file = %v, want the error naming the path", err)
+	}
+}
@@ -0,0 +1,113 @@
+package sftps
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config is the named configuration of the SFTP client, the zero value of each field keeps its default.
+type Config struct {
+	// Host and User are required, and at least one of the Password, the PrivateKey and the Agent authenticates.
+	Host string
+	// Port defaults to 22 when zero.
+	Port     int
+	User     string
+	Password string
+	// PrivateKey is the content of the private key or its path prefixed with "file:///".
+	PrivateKey string
+	// Passphrase decrypts the PrivateKey when not empty.
+	Passphrase string
+	// KnownHosts is the known_hosts file used to verify the host key, defaults to "~/.ssh/known_hosts".
+	KnownHosts string
+	// InsecureSkipHostKeyCheck accepts any host key, NOT recommended.
+	InsecureSkipHostKeyCheck bool
+	// Timeout bounds each step of the connect on its own: the DNS resolution, each TCP dial attempt and the SSH handshake.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// Agent authenticates with the SSH agent listening on the AgentSocket, or on SSH_AUTH_SOCK when it is empty.
+	Agent       bool
+	AgentSocket string
+	// AtomicUpload uploads to "[remote].part" and renames it into place after the copy succeeded.
+	AtomicUpload bool
+	// KeepAlive keeps the connection open between the operations.
+	KeepAlive bool
+	// JumpHost is the bastion server that the connection is tunneled through, its KeepAlive is ignored.
+	JumpHost *Config
+}
+
+// NewClient validates the cfg and creates the SFTP client, the connection is established by Connect.
+// It returns the error when the host, the user or every authentication method is missing.
+func NewClient(cfg Config) (client *Sftps, err error) {
+	var param *sftpParameters
+	if param, err = cfg.parameters(); err != nil {
+		return
+	}
+	if err = param.validateAuth(); err != nil {
+		return
+	}
+	client, err = New(SFTP, param)
+	return
+}
+
+func (cfg Config) parameters() (param *sftpParameters, err error) {
+	param = cfg.build()
+	if err = param.validate(); err != nil {
+		param = nil
+		return
+	}
+	if cfg.JumpHost != nil {
+		if param.jumpHost, err = cfg.JumpHost.parameters(); err != nil {
+			param = nil
+			return
+		}
+	}
+	return
+}
+
+// build creates the parameters from the cfg without the validation.
+func (cfg Config) build() (param *sftpParameters) {
+	param = &sftpParameters{
+		host:         cfg.Host,
+		port:         cfg.Port,
+		user:         cfg.User,
+		pass:         cfg.Password,
+		keys:         []*sftpKey{},
+		keepAlive:    cfg.KeepAlive,
+		knownHosts:   cfg.KnownHosts,
+		insecure:     cfg.InsecureSkipHostKeyCheck,
+		jumpHost:     nil,
+		timeout:      cfg.Timeout,
+		useAgent:     cfg.Agent,
+		agentSocket:  cfg.AgentSocket,
+		challenge:    nil,
+		atomicUpload: cfg.AtomicUpload,
+		reconnect:    nil,
+	}
+	param.keepAliveInterval, _ = time.ParseDuration(KEEPALIVE)
+	if cfg.PrivateKey != "" {
+		param.Keys(cfg.PrivateKey, cfg.Passphrase != "", cfg.Passphrase)
+	}
+	return
+}
+
+func (param *sftpParameters) validate() (err error) {
+	if param.host == "" || param.user == "" {
+		err = errors.New("Invalid parameter were bound. the Host and the User must not be empty.")
+		return
+	}
+	switch param.network {
+	case "", "tcp", "tcp4", "tcp6":
+	default:
+		err = fmt.Errorf(`Invalid parameter were bound. the network must be "tcp", "tcp4" or "tcp6", not "%v".`, param.network)
+	}
+	return
+}
+
+// validateAuth checks that the server and the jump host have at least one authentication method each.
+func (param *sftpParameters) validateAuth() (err error) {
+	if !param.hasAuth() || (param.jumpHost != nil && !param.jumpHost.hasAuth()) {
+		err = errors.New("Invalid parameter were bound. at least one authentication method must be provided.")
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package sftps
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewClientValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing host", Config{User: "user", Password: "pass"}},
+		{"missing user", Config{Host: "example.com", Password: "pass"}},
+		{"missing auth", Config{Host: "example.com", User: "user"}},
+		{"jump host without auth", Config{Host: "example.com", User: "user", Password: "pass", JumpHost: &Config{Host: "bastion", User: "user"}}},
+	}
+	for _, test := range tests {
+		if client, err := NewClient(test.cfg); err == nil || client != nil {
+			t.Errorf("NewClient with the %s = %v, %v, want the error", test.name, client, err)
+		}
+	}
+	if _, err := NewClient(Config{Host: "example.com", User: "user", Password: "pass"}); err != nil {
+		t.Errorf("NewClient = %v", err)
+	}
+}
+
+func TestConnectFailedSession(t *testing.T) {
+	// The server accepts the subsystem request but closes the channel before the SFTP session starts.
+	port := startTestServer(t, func(ch ssh.Channel) {})
+	param := NewSftpParameters("127.0.0.1", port, testUser, testPassword, true)
+	param.InsecureSkipHostKeyCheck()
+	client, err := New(SFTP, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Connect(); err == nil {
+		t.Fatal("Connect without the SFTP session succeeded")
+	}
+}
+
+func TestNewSftpClientNetwork(t *testing.T) {
+	for _, network := range []string{"tcp", "tcp4", "tcp6"} {
+		if _, err := NewSftpClient("example.com", WithUser("user"), WithPassword("pass"), WithNetwork(network)); err != nil {
+			t.Errorf("NewSftpClient with the network %q = %v", network, err)
+		}
+	}
+	for _, network := range []string{"udp", "unix", "ip4", "TCP"} {
+		if _, err := NewSftpClient("example.com", WithUser("user"), WithPassword("pass"), WithNetwork(network)); err == nil {
+			t.Errorf("NewSftpClient with the network %q succeeded", network)
+		}
+	}
+}
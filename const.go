@@ -23,10 +23,15 @@ const (
 )
 
 const (
-	// When handshake to the server
+	// When handshake to the server, and the default bound of the "ls" command of the List.
 	TIMEOUT = "10s"
 	// The Keep Alive Period for an active network connection.
 	KEEPALIVE = "30s"
 
 	FILEPROTOCOL = "file:///"
 )
+
+const (
+	// The number of the stat requests in flight of the StatMany.
+	STATCONCURRENCY = 16
+)
@@ -0,0 +1,261 @@
+package sftps
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// ProgressFunc is called periodically during the transfer with the bytes transferred so far,
+// the total is -1 when the size of the source is unknown (e.g. a streaming source).
+type ProgressFunc func(transferred int64, total int64)
+
+type progressWriter struct {
+	w           io.Writer
+	total       int64
+	transferred int64
+	progress    ProgressFunc
+}
+
+func newProgressWriter(w io.Writer, total int64, progress ProgressFunc) *progressWriter {
+	return &progressWriter{
+		w:        w,
+		total:    total,
+		progress: progress,
+	}
+}
+
+func (this *progressWriter) Write(p []byte) (n int, err error) {
+	n, err = this.w.Write(p)
+	this.transferred += int64(n)
+	this.progress(this.transferred, this.total)
+	return
+}
+
+// sizeOf returns the size of the src when it is a regular file providing Stat, otherwise -1.
+func sizeOf(src interface{}) int64 {
+	if s, ok := src.(interface {
+		Stat() (os.FileInfo, error)
+	}); ok {
+		if info, err := s.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+	return -1
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// copy copies the src to the dst honoring the transfer parameters such as the rate limit and the stall timeout.
+// The remote is the remote file of the copy, it is closed to interrupt the stalled or the cancelled copy.
+// The remote *sftp.File is read by its WriteTo and written by its ReadFrom, both issue the concurrent requests.
+func (this *SecureFtp) copy(dst io.Writer, src io.Reader, remote io.Closer) (len int64, err error) {
+	return this.copyContext(context.Background(), dst, src, remote)
+}
+
+// copyContext is the copy checking the ctx between the chunks, it stops with the error of the ctx once the ctx is done.
+func (this *SecureFtp) copyContext(ctx context.Context, dst io.Writer, src io.Reader, remote io.Closer) (len int64, err error) {
+	return this.copyLimited(ctx, dst, src, remote, this.newRateLimiter())
+}
+
+// newRateLimiter returns the limiter of the rate limit parameter, nil when the rate is unlimited.
+func (this *SecureFtp) newRateLimiter() *rateLimiter {
+	if this.params.rateLimit <= 0 {
+		return nil
+	}
+	return newRateLimiter(this.params.rateLimit)
+}
+
+// copyLimited is the copyContext writing through the limiter, the copies sharing the limiter share its rate.
+// The nil limiter does not limit the rate.
+func (this *SecureFtp) copyLimited(ctx context.Context, dst io.Writer, src io.Reader, remote io.Closer, limiter *rateLimiter) (len int64, err error) {
+	ctx, dst, release := this.watchStall(ctx, dst)
+	defer release()
+	// The read or the write blocked on the remote file only returns once the file is closed.
+	if ctx.Done() != nil && remote != nil {
+		stop := closeOnDone(ctx, remote)
+		defer func() {
+			if e := stop(); e != nil {
+				err = e
+			}
+		}()
+	}
+	if limiter != nil {
+		dst = &rateLimitedWriter{w: dst, limiter: limiter}
+	}
+	// The background ctx is never done, the src is kept as it is so that its WriterTo is still used.
+	if ctx.Done() != nil {
+		src = &contextReader{ctx: ctx, r: src}
+	}
+	if size := this.params.copyBufferSize; size > 0 {
+		buf := getCopyBuffer(size)
+		defer copyBuffers.Put(buf)
+		// The WriteTo and the ReadFrom are hidden so that the io.CopyBuffer goes through the buf.
+		len, err = io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+		return
+	}
+	// The *sftp.File dst pipelines the writes by its ReadFrom, the io.Copy would prefer the WriteTo of the src instead.
+	if f, ok := dst.(*sftp.File); ok {
+		len, err = f.ReadFrom(src)
+		return
+	}
+	len, err = io.Copy(dst, src)
+	return
+}
+
+// watchStall returns the ctx cancelled with the ErrStalled once no byte is written to the returned dst for the stall timeout,
+// the release stops the watchdog. Without the stall timeout the ctx and the dst are returned as they are.
+// The stall closes the SSH connection, the hung server answers neither the pending reads and writes nor the Close
+// of the remote file, so the connection must be established again for the next operation.
+func (this *SecureFtp) watchStall(ctx context.Context, dst io.Writer) (_ context.Context, _ io.Writer, release func()) {
+	timeout := this.params.stallTimeout
+	if timeout <= 0 {
+		return ctx, dst, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	// The connection of the copy is closed, not the one established meanwhile by the reconnect.
+	conn := this.sshClient
+	timer := time.AfterFunc(timeout, func() {
+		cancel(ErrStalled)
+		if conn != nil {
+			conn.Close()
+		}
+	})
+	release = func() {
+		timer.Stop()
+		cancel(nil)
+	}
+	return ctx, &stallWriter{w: dst, timer: timer, timeout: timeout}, release
+}
+
+// stallWriter resets the timer of the watchStall on each chunk written.
+type stallWriter struct {
+	w       io.Writer
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (this *stallWriter) Write(p []byte) (n int, err error) {
+	n, err = this.w.Write(p)
+	if n > 0 {
+		this.timer.Reset(this.timeout)
+	}
+	return
+}
+
+// closers closes every closer, e.g. both remote files of the remote copy.
+type closers []io.Closer
+
+func (this closers) Close() (err error) {
+	for _, c := range this {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// copyBuffers pools the buffers of the copyContext, a buffer smaller than the requested size is dropped.
+var copyBuffers sync.Pool
+
+func getCopyBuffer(size int) *[]byte {
+	if buf, ok := copyBuffers.Get().(*[]byte); ok && cap(*buf) >= size {
+		*buf = (*buf)[:size]
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (this *contextReader) Read(p []byte) (n int, err error) {
+	if this.ctx.Err() != nil {
+		err = context.Cause(this.ctx)
+		return
+	}
+	return this.r.Read(p)
+}
+
+// rateLimiter caps the rate of the writes with the token bucket, the bucket is safe for the concurrent writers
+// which then share the rate. The writes are split into the chunks not exceeding a tenth of the rate
+// so that the bursts are smoothed rather than a huge chunk followed by a long sleep.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	burst := int(rate / 10)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:  rate,
+		burst: burst,
+		last:  time.Now(),
+	}
+}
+
+// wait blocks until the n bytes are allowed by the bucket. The bytes are taken from the bucket before the sleep,
+// so the concurrent writers queue behind each other instead of spending the same tokens.
+func (this *rateLimiter) wait(n int) {
+	this.mu.Lock()
+	now := time.Now()
+	this.tokens += now.Sub(this.last).Seconds() * float64(this.rate)
+	if this.tokens > float64(this.burst) {
+		this.tokens = float64(this.burst)
+	}
+	this.last = now
+	this.tokens -= float64(n)
+	var delay time.Duration
+	if this.tokens < 0 {
+		delay = time.Duration(-this.tokens / float64(this.rate) * float64(time.Second))
+	}
+	this.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// rateLimitedWriter writes to the w at the rate of the limiter.
+type rateLimitedWriter struct {
+	w       io.Writer
+	limiter *rateLimiter
+}
+
+func newRateLimitedWriter(w io.Writer, rate int64) *rateLimitedWriter {
+	return &rateLimitedWriter{w: w, limiter: newRateLimiter(rate)}
+}
+
+func (this *rateLimitedWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > this.limiter.burst {
+			chunk = chunk[:this.limiter.burst]
+		}
+		this.limiter.wait(len(chunk))
+		var m int
+		m, err = this.w.Write(chunk)
+		n += m
+		if err != nil {
+			return
+		}
+		p = p[m:]
+	}
+	return
+}
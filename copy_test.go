@@ -0,0 +1,70 @@
+package sftps
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	// The burst is a tenth of the rate, the 300 bytes beyond it take about 0.3s at 1000 bytes per second.
+	w := newRateLimitedWriter(&buf, 1000)
+	data := bytes.Repeat([]byte("x"), 400)
+	start := time.Now()
+	n, err := w.Write(data)
+	if err != nil || n != len(data) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Write of 400 bytes at 1000 bytes per second took %v", elapsed)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("the written content differs")
+	}
+}
+
+func TestRateLimitedWriterTinyRate(t *testing.T) {
+	// The burst never drops below a byte, otherwise the Write would loop forever.
+	w := newRateLimitedWriter(new(bytes.Buffer), 5)
+	if w.limiter.burst != 1 {
+		t.Errorf("burst = %d, want 1", w.limiter.burst)
+	}
+}
+
+func TestRateLimiterShared(t *testing.T) {
+	// The two writers share the 1000 bytes per second, together their 600 bytes take about 0.6s.
+	limiter := newRateLimiter(1000)
+	done := make(chan struct{})
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		go func() {
+			w := &rateLimitedWriter{w: io.Discard, limiter: limiter}
+			w.Write(bytes.Repeat([]byte("x"), 300))
+			done <- struct{}{}
+		}()
+	}
+	<-done
+	<-done
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("the 600 bytes shared at 1000 bytes per second took %v", elapsed)
+	}
+}
+
+func BenchmarkCopyBufferSize(b *testing.B) {
+	port := newTestServer(b)
+	data, remote := newBenchFile(b, b.TempDir())
+	for _, size := range []int{32 * 1024, 256 * 1024, 1024 * 1024} {
+		client := newTestClient(b, port, WithCopyBufferSize(size))
+		b.Run(fmt.Sprintf("%dKB", size/1024), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := client.DownloadTo(io.Discard, remote); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package sftps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DirOptions controls the recursive transfer of a directory.
+type DirOptions struct {
+	// FollowSymlinks transfers the targets of the symlinks, the symlinks are skipped when false.
+	// A directory reached again through a symlink is transferred only once.
+	FollowSymlinks bool
+	// ContinueOnError keeps transferring the remaining files when a file failed,
+	// the failures are collected into the DirSummary instead of aborting.
+	ContinueOnError bool
+	// PreserveMode applies the mode bits of the source files and directories to the created ones,
+	// e.g. the executable scripts keep their +x bit.
+	PreserveMode bool
+	// Progress is called after each file is transferred.
+	Progress func(local string, remote string, len int64)
+}
+
+// WalkOptions controls the walk of the remote tree.
+type WalkOptions struct {
+	// FollowSymlinks descends into the directories the symlinks point to and reports the FileInfo of the targets,
+	// the symlinks are reported as themselves and not followed when false. A directory reached again through a symlink
+	// (by its real path) is reported without descending into it again, so the self-referential links do not loop.
+	FollowSymlinks bool
+}
+
+// DirSummary reports the result of the recursive transfer of a directory.
+type DirSummary struct {
+	Files  int
+	Dirs   int
+	Bytes  int64
+	Errors []error
+}
+
+type dirTransfer struct {
+	sftp    *SecureFtp
+	opts    *DirOptions
+	summary *DirSummary
+	visited map[string]bool
+}
+
+func newDirTransfer(sftp *SecureFtp, opts *DirOptions) (t *dirTransfer) {
+	if opts == nil {
+		opts = new(DirOptions)
+	}
+	t = &dirTransfer{
+		sftp:    sftp,
+		opts:    opts,
+		summary: new(DirSummary),
+		visited: map[string]bool{},
+	}
+	return
+}
+
+// fail returns the err when the transfer must be aborted, otherwise the err is collected into the summary.
+func (this *dirTransfer) fail(err error) error {
+	if !this.opts.ContinueOnError {
+		return err
+	}
+	this.summary.Errors = append(this.summary.Errors, err)
+	return nil
+}
+
+func (this *SecureFtp) uploadDir(localRoot string, remoteRoot string, opts *DirOptions) (summary *DirSummary, err error) {
+	remoteRoot = this.resolve(remoteRoot)
+	t := newDirTransfer(this, opts)
+	err = t.upload(localRoot, remoteRoot)
+	summary = t.summary
+	return
+}
+
+func (this *dirTransfer) upload(localRoot string, remoteRoot string) error {
+	if resolved, err := filepath.EvalSymlinks(localRoot); err == nil {
+		// Prevents the infinite recursion caused by the symlinks to an ancestor.
+		if this.visited[resolved] {
+			return nil
+		}
+		this.visited[resolved] = true
+	}
+	return filepath.Walk(localRoot, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return this.fail(fmt.Errorf(`Upload "%v": %w`, local, err))
+		}
+		rel, err := filepath.Rel(localRoot, local)
+		if err != nil {
+			return this.fail(fmt.Errorf(`Upload "%v": %w`, local, err))
+		}
+		remote := path.Join(remoteRoot, filepath.ToSlash(rel))
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !this.opts.FollowSymlinks {
+				return nil
+			}
+			target, err := filepath.EvalSymlinks(local)
+			if err == nil {
+				info, err = os.Stat(target)
+			}
+			if err != nil {
+				return this.fail(fmt.Errorf(`Upload "%v": %w`, local, err))
+			}
+			if info.IsDir() {
+				return this.upload(target, remote)
+			}
+			local = target
+		}
+
+		if info.IsDir() {
+			err := this.sftp.mkdirAll(remote)
+			if err == nil && this.opts.PreserveMode {
+				err = this.sftp.chmod(remote, info.Mode().Perm())
+			}
+			if err != nil {
+				if e := this.fail(err); e != nil {
+					return e
+				}
+				return filepath.SkipDir
+			}
+			this.summary.Dirs++
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		len, err := this.sftp.put(local, remote)
+		this.summary.Bytes += len
+		if err == nil && this.opts.PreserveMode {
+			err = this.sftp.chmod(remote, info.Mode().Perm())
+		}
+		if err != nil {
+			return this.fail(fmt.Errorf(`Upload "%v" to "%v": %w`, local, remote, err))
+		}
+		this.summary.Files++
+		if this.opts.Progress != nil {
+			this.opts.Progress(local, remote, len)
+		}
+		return nil
+	})
+}
+
+func (this *SecureFtp) downloadDir(remoteRoot string, localRoot string, opts *DirOptions) (summary *DirSummary, err error) {
+	remoteRoot = this.resolve(remoteRoot)
+	t := newDirTransfer(this, opts)
+	var info os.FileInfo
+	if info, err = this.sftpClient.Stat(remoteRoot); err != nil {
+		err = fmt.Errorf(`Download "%v": %w`, remoteRoot, err)
+		summary = t.summary
+		return
+	}
+	err = t.download(remoteRoot, this.resolveLinks(path.Dir(remoteRoot), path.Base(remoteRoot)), localRoot, info)
+	summary = t.summary
+	return
+}
+
+// download mirrors the remote directory, the real is the remoteDir with the symlinks resolved
+// and the info is the information of the remote directory.
+func (this *dirTransfer) download(remoteDir string, real string, localDir string, info os.FileInfo) error {
+	if this.opts.FollowSymlinks {
+		// Prevents the infinite recursion caused by the symlinks to an ancestor.
+		if this.visited[real] {
+			return nil
+		}
+		this.visited[real] = true
+	}
+	mode := os.FileMode(0755)
+	if this.opts.PreserveMode {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(localDir, mode); err != nil {
+		return this.fail(fmt.Errorf(`Mkdir "%v": %w`, localDir, err))
+	}
+	if this.opts.PreserveMode {
+		if err := os.Chmod(localDir, mode); err != nil {
+			return this.fail(fmt.Errorf(`Chmod "%v": %w`, localDir, err))
+		}
+	}
+	this.summary.Dirs++
+
+	entries, err := this.sftp.sftpClient.ReadDir(remoteDir)
+	if err != nil {
+		return this.fail(fmt.Errorf(`Download "%v": %w`, remoteDir, err))
+	}
+	for _, entry := range entries {
+		remote := path.Join(remoteDir, entry.Name())
+		local := filepath.Join(localDir, entry.Name())
+		childReal := path.Join(real, entry.Name())
+
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if !this.opts.FollowSymlinks {
+				continue
+			}
+			childReal = this.sftp.resolveLinks(real, entry.Name())
+			if entry, err = this.sftp.sftpClient.Stat(remote); err != nil {
+				if e := this.fail(fmt.Errorf(`Download "%v": %w`, remote, err)); e != nil {
+					return e
+				}
+				continue
+			}
+		}
+
+		if entry.IsDir() {
+			if err := this.download(remote, childReal, local, entry); err != nil {
+				return err
+			}
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+
+		len, err := this.sftp.get(local, remote)
+		this.summary.Bytes += len
+		if err == nil && this.opts.PreserveMode {
+			err = os.Chmod(local, entry.Mode().Perm())
+		}
+		if err != nil {
+			if e := this.fail(fmt.Errorf(`Download "%v" to "%v": %w`, remote, local, err)); e != nil {
+				return e
+			}
+			continue
+		}
+		this.summary.Files++
+		if this.opts.Progress != nil {
+			this.opts.Progress(local, remote, len)
+		}
+	}
+	return nil
+}
+
+// walk calls the fn for each path of the remote tree rooted at the root, the filepath.SkipDir returned by the fn prunes the directory.
+func (this *SecureFtp) walk(root string, fn filepath.WalkFunc) (err error) {
+	return this.walkContext(context.Background(), root, fn)
+}
+
+// walkContext is the walk stopped with the error of the ctx once the ctx is done, the ctx is checked before each entry.
+func (this *SecureFtp) walkContext(ctx context.Context, root string, fn filepath.WalkFunc) (err error) {
+	root = this.resolve(root)
+	walker := this.sftpClient.Walk(root)
+	for walker.Step() {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		err = fn(walker.Path(), walker.Stat(), walker.Err())
+		if err == nil {
+			continue
+		}
+		if err == filepath.SkipDir {
+			err = nil
+			if info := walker.Stat(); info != nil && info.IsDir() {
+				walker.SkipDir()
+			}
+			continue
+		}
+		if err == filepath.SkipAll {
+			err = nil
+		}
+		return
+	}
+	return
+}
+
+// walkOptions is the walkContext following the symlinks when the opts ask for it.
+func (this *SecureFtp) walkOptions(ctx context.Context, root string, opts WalkOptions, fn filepath.WalkFunc) (err error) {
+	if !opts.FollowSymlinks {
+		return this.walkContext(ctx, root, fn)
+	}
+	root = this.resolve(root)
+	var info os.FileInfo
+	if info, err = this.sftpClient.Stat(root); err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = this.walkFollow(ctx, root, this.resolveLinks(path.Dir(root), path.Base(root)), info, map[string]bool{}, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		err = nil
+	}
+	return
+}
+
+// walkFollow calls the fn for the p and, when it is a directory not visited yet, for its entries with the symlinks resolved.
+// The real is the p with the symlinks resolved, the directories are visited once by their real paths.
+// The errors of the fn are handled in the same manner as filepath.Walk.
+func (this *SecureFtp) walkFollow(ctx context.Context, p string, real string, info os.FileInfo, visited map[string]bool, fn filepath.WalkFunc) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if !info.IsDir() {
+		return fn(p, info, nil)
+	}
+	if visited[real] {
+		return fn(p, info, nil)
+	}
+	visited[real] = true
+	var entries []os.FileInfo
+	entries, err = this.sftpClient.ReadDir(p)
+	if e := fn(p, info, err); err != nil || e != nil {
+		return e
+	}
+	for _, entry := range entries {
+		if entry.Name() == "." || entry.Name() == ".." {
+			continue
+		}
+		child := path.Join(p, entry.Name())
+		childReal := path.Join(real, entry.Name())
+		if entry.Mode()&os.ModeSymlink != 0 {
+			childReal = this.resolveLinks(real, entry.Name())
+			target, e := this.sftpClient.Stat(child)
+			if e != nil {
+				if err = fn(child, entry, e); err != nil {
+					return
+				}
+				continue
+			}
+			entry = target
+		}
+		if err = this.walkFollow(ctx, child, childReal, entry, visited, fn); err != nil {
+			if !entry.IsDir() || err != filepath.SkipDir {
+				return
+			}
+			err = nil
+		}
+	}
+	return
+}
+
+// maxSymlinks bounds the symlinks resolved in a row by the resolveLinks, like the ELOOP limit of Linux.
+const maxSymlinks = 40
+
+// resolveLinks returns the path of the name in the dir with the symlinks resolved, the dir must be resolved already.
+// The symlinks are read one by one by the ReadLink and the result is given to the RealPath, some servers
+// (e.g. the one of the pkg/sftp) answer the RealPath by cleaning the path without resolving the symlinks.
+// The symlink which can not be read is kept as it is.
+func (this *SecureFtp) resolveLinks(dir string, name string) string {
+	p := path.Join(dir, name)
+	for i := 0; i < maxSymlinks; i++ {
+		info, err := this.sftpClient.Lstat(p)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		target, err := this.sftpClient.ReadLink(p)
+		if err != nil {
+			break
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		p = path.Clean(target)
+	}
+	if real, err := this.sftpClient.RealPath(p); err == nil {
+		return real
+	}
+	return p
+}
+
+// removeAll removes the path and all of its contents, the files are removed before their parent directories.
+// The symlinks are removed themselves, never followed. It returns the error identifying the first path that could not be removed.
+func (this *SecureFtp) removeAll(p string) (err error) {
+	p = this.resolve(p)
+	var info os.FileInfo
+	if info, err = this.sftpClient.Lstat(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf(`Remove "%v": %w`, p, err)
+		return
+	}
+	if this.params.dryRun {
+		this.logDryRun("remove all", "path", p)
+		return
+	}
+	if !info.IsDir() {
+		return this.remove(p)
+	}
+	var entries []os.FileInfo
+	if entries, err = this.sftpClient.ReadDir(p); err != nil {
+		err = fmt.Errorf(`Remove "%v": %w`, p, err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() == "." || entry.Name() == ".." {
+			continue
+		}
+		if err = this.removeAll(path.Join(p, entry.Name())); err != nil {
+			return
+		}
+	}
+	if err = this.sftpClient.RemoveDirectory(p); err != nil {
+		err = fmt.Errorf(`Remove "%v": %w`, p, err)
+	}
+	return
+}
@@ -0,0 +1,124 @@
+package sftps
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestUploadDirSummary(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"a.txt": "a", "sub/b.txt": "bb"} {
+		if err := os.WriteFile(filepath.Join(src, filepath.FromSlash(name)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary, err := client.UploadDir(src, filepath.ToSlash(filepath.Join(dir, "remote")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The root counts as the directory too.
+	if summary.Files != 2 || summary.Dirs != 2 || summary.Bytes != 3 || len(summary.Errors) != 0 {
+		t.Fatalf("UploadDir summary = %+v, want 2 files, 2 dirs and 3 bytes", summary)
+	}
+}
+
+func TestRealPath(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := filepath.ToSlash(t.TempDir())
+	if real, err := client.RealPath(dir + "/sub/../a.txt"); err != nil || real != dir+"/a.txt" {
+		t.Fatalf("RealPath = %q, %v, want %q", real, err, dir+"/a.txt")
+	}
+}
+
+func TestFollowSymlinkLoop(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	root := filepath.Join(t.TempDir(), "root")
+	writeTestTree(t, root, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	// The relative link to its own directory and the absolute link to an ancestor.
+	if err := os.Symlink(".", filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(root, filepath.Join(root, "sub", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	err := client.WalkWithOptions(filepath.ToSlash(root), WalkOptions{FollowSymlinks: true}, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, filepath.FromSlash(p))
+		walked = append(walked, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(walked)
+	// The links to the visited directories are reported once but not descended into.
+	if got, want := strings.Join(walked, ","), ".,a.txt,loop,sub,sub/b.txt,sub/up"; err != nil || got != want {
+		t.Fatalf("WalkWithOptions = %v, %v, want %v", got, err, want)
+	}
+
+	local := filepath.Join(t.TempDir(), "local")
+	summary, err := client.DownloadDir(filepath.ToSlash(root), local, &DirOptions{FollowSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Files != 2 || summary.Dirs != 2 {
+		t.Fatalf("DownloadDir = %d files and %d directories, want 2 and 2", summary.Files, summary.Dirs)
+	}
+	for _, name := range []string{"loop", filepath.Join("sub", "up")} {
+		if _, err := os.Lstat(filepath.Join(local, name)); !os.IsNotExist(err) {
+			t.Errorf("the link %s to the visited directory was downloaded, %v", name, err)
+		}
+	}
+}
+
+func TestFollowSymlinkedDir(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeTestTree(t, dir, map[string]string{"root/a.txt": "a", "other/b.txt": "b"})
+	if err := os.Symlink(filepath.Join(dir, "other"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	err := client.WalkWithOptions(filepath.ToSlash(root), WalkOptions{FollowSymlinks: true}, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, filepath.FromSlash(p))
+		walked = append(walked, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(walked)
+	if got, want := strings.Join(walked, ","), ".,a.txt,link,link/b.txt"; err != nil || got != want {
+		t.Fatalf("WalkWithOptions = %v, %v, want %v", got, err, want)
+	}
+	// Without the FollowSymlinks the link is reported but not descended into.
+	walked = nil
+	err = client.WalkWithOptions(filepath.ToSlash(root), WalkOptions{}, func(p string, info os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(root, filepath.FromSlash(p))
+		walked = append(walked, filepath.ToSlash(rel))
+		return err
+	})
+	sort.Strings(walked)
+	if got, want := strings.Join(walked, ","), ".,a.txt,link"; err != nil || got != want {
+		t.Fatalf("WalkWithOptions without following = %v, %v, want %v", got, err, want)
+	}
+
+	local := filepath.Join(dir, "local")
+	if _, err = client.DownloadDir(filepath.ToSlash(root), local, &DirOptions{FollowSymlinks: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(local, "link", "b.txt")); err != nil || string(data) != "b" {
+		t.Fatalf("the file downloaded through the link = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,118 @@
+package sftps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrExists is returned by the upload when the remote file exists and the no-clobber is enabled.
+var ErrExists = errors.New("The remote file already exists.")
+
+// ErrStalled is returned by the transfer aborted by the stall timeout, see WithStallTimeout,
+// it wraps the os.ErrDeadlineExceeded so that it is reported as the timeout.
+var ErrStalled = fmt.Errorf("The transfer made no progress: %w", os.ErrDeadlineExceeded)
+
+// AuthError is returned when the client or the server could not be authenticated,
+// e.g. all the auth methods were rejected or the host key does not match the known_hosts file.
+// It is permanent, retrying with the same parameters is futile.
+type AuthError struct {
+	Host string
+	Err  error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf(`Authentication with "%v" failed: %v`, e.Host, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// ConnectionError is returned when the server could not be reached or the connection is lost, it is usually transient.
+type ConnectionError struct {
+	Host string
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf(`Connection to "%v" failed: %v`, e.Host, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError is returned by the connect when a local file such as the private key or the known_hosts file does not exist,
+// errors.Is(err, os.ErrNotExist) holds. The operations on the remote files return their error wrapped with the path,
+// the IsNotFound covers both.
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether the err is a NotFoundError or indicates that the file does not exist.
+func IsNotFound(err error) bool {
+	var e *NotFoundError
+	return errors.As(err, &e) || errors.Is(err, os.ErrNotExist)
+}
+
+// IsPermission reports whether the err indicates that the access is denied.
+func IsPermission(err error) bool {
+	return errors.Is(err, os.ErrPermission)
+}
+
+// IsAuth reports whether the err is an AuthError.
+func IsAuth(err error) bool {
+	var e *AuthError
+	return errors.As(err, &e)
+}
+
+// IsConnection reports whether the err is a ConnectionError.
+func IsConnection(err error) bool {
+	var e *ConnectionError
+	return errors.As(err, &e)
+}
+
+// classified reports whether the err is already one of the error types.
+func classified(err error) bool {
+	var n *NotFoundError
+	return IsAuth(err) || IsConnection(err) || errors.As(err, &n)
+}
+
+// classify wraps the lost connection to the host into the ConnectionError, the other errors are kept as they are
+// since they already wrap the cause with the path, e.g. the IsNotFound and the IsPermission hold through the wrapping.
+func classify(host string, err error) error {
+	if err == nil || classified(err) {
+		return err
+	}
+	if isConnectionLost(err) {
+		return &ConnectionError{Host: host, Err: err}
+	}
+	return err
+}
+
+// classifyConnect wraps the err of the connection to the host, the rejected auth methods are the AuthError,
+// a missing file such as the private key or the known_hosts file is the NotFoundError
+// and whatever else prevented the connection is the ConnectionError.
+func classifyConnect(host string, err error) error {
+	if err == nil || classified(err) {
+		return err
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return &NotFoundError{Err: err}
+	}
+	// x/crypto/ssh reports the rejected auth methods with the plain error only.
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return &AuthError{Host: host, Err: err}
+	}
+	return &ConnectionError{Host: host, Err: err}
+}
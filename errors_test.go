@@ -0,0 +1,90 @@
+package sftps
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestStatMissingFile(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	_, err := client.Stat(filepath.ToSlash(filepath.Join(t.TempDir(), "missing.txt")))
+	if !os.IsNotExist(err) || !errors.Is(err, os.ErrNotExist) || !IsNotFound(err) {
+		t.Fatalf("Stat of the missing file = %#v", err)
+	}
+	if ok, err := client.Exists(filepath.ToSlash(filepath.Join(t.TempDir(), "missing.txt"))); ok || err != nil {
+		t.Fatalf("Exists of the missing file = %v, %v", ok, err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	if err := classify("host", nil); err != nil {
+		t.Errorf("classify(nil) = %v", err)
+	}
+	// The missing file and the denied access keep their wrapping and the path of the message.
+	missing := fmt.Errorf(`Rename "a" to "b": %w`, os.ErrNotExist)
+	if err := classify("host", missing); err != missing || !IsNotFound(err) || IsPermission(err) || IsConnection(err) {
+		t.Errorf("classify of the missing file = %#v, want it kept", err)
+	}
+	denied := fmt.Errorf(`Upload "a": %w`, fmt.Errorf(`Create "a.part": %w`, os.ErrPermission))
+	if err := classify("host", denied); err != denied || !IsPermission(err) || IsNotFound(err) {
+		t.Errorf("classify of the denied access = %#v, want it kept", err)
+	}
+	for _, lost := range []error{io.EOF, sftp.ErrSSHFxConnectionLost, io.ErrUnexpectedEOF} {
+		if err := classify("host", lost); !IsConnection(err) {
+			t.Errorf("classify(%v) = %#v, want the ConnectionError", lost, err)
+		}
+	}
+	other := errors.New("other")
+	if err := classify("host", other); err != other {
+		t.Errorf("classify of the other error = %#v, want it kept", err)
+	}
+	// The error classified already is not wrapped twice.
+	lost := classify("host", io.EOF)
+	if err := classify("host", lost); err != lost {
+		t.Errorf("classify of the classified error = %#v, want it kept", err)
+	}
+}
+
+func TestClassifyConnect(t *testing.T) {
+	auth := classifyConnect("host", errors.New("ssh: handshake failed: ssh: unable to authenticate"))
+	if !IsAuth(auth) || IsConnection(auth) {
+		t.Errorf("classifyConnect of the rejected auth = %#v", auth)
+	}
+	if err := classifyConnect("host", errors.New("connection refused")); !IsConnection(err) {
+		t.Errorf("classifyConnect of the refused connection = %#v", err)
+	}
+	if err := classifyConnect("host", fmt.Errorf("key: %w", os.ErrNotExist)); !IsNotFound(err) {
+		t.Errorf("classifyConnect of the missing key = %#v", err)
+	}
+}
+
+func TestConnectWrongPassword(t *testing.T) {
+	param := NewSftpParameters("127.0.0.1", newTestServer(t), testUser, "wrong", true)
+	param.InsecureSkipHostKeyCheck()
+	client, err := New(SFTP, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Connect(); !IsAuth(err) {
+		t.Fatalf("Connect with the wrong password = %v, want the AuthError", err)
+	}
+}
+
+func TestRenameMissingKeepsBothPaths(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := filepath.ToSlash(filepath.Join(t.TempDir(), "nonexistent"))
+	_, err := client.Rename(dir+"/a", dir+"/b")
+	if !IsNotFound(err) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Rename of the missing file = %#v, want the not found error", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, dir+"/a") || !strings.Contains(msg, dir+"/b") {
+		t.Fatalf("Rename of the missing file = %q, want the error naming both paths", msg)
+	}
+}
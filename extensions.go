@@ -0,0 +1,103 @@
+package sftps
+
+import (
+	"fmt"
+
+	"github.com/pkg/sftp"
+)
+
+// The SFTP protocol extensions known by this package, the server advertises the ones it supports.
+var knownExtensions = []string{
+	"posix-rename@openssh.com",
+	"statvfs@openssh.com",
+	"fstatvfs@openssh.com",
+	"hardlink@openssh.com",
+	"fsync@openssh.com",
+	"lsetstat@openssh.com",
+	"limits@openssh.com",
+	"expand-path@openssh.com",
+	"copy-data",
+	"home-directory",
+	"users-groups-by-id@openssh.com",
+	"check-file",
+}
+
+// serverExtensions returns the known extensions advertised by the server with their versions.
+// The underlying client only answers the queries by name, so the unknown extensions are not listed.
+func (this *SecureFtp) serverExtensions() (exts map[string]string) {
+	exts = map[string]string{}
+	for _, name := range knownExtensions {
+		if version, ok := this.sftpClient.HasExtension(name); ok {
+			exts[name] = version
+		}
+	}
+	return
+}
+
+func (this *SecureFtp) hasExtension(name string) bool {
+	_, ok := this.sftpClient.HasExtension(name)
+	return ok
+}
+
+// requireExtension returns the error when the server does not advertise the extension.
+func (this *SecureFtp) requireExtension(name string) (err error) {
+	if !this.hasExtension(name) {
+		err = fmt.Errorf(`The server does not support the "%v" extension.`, name)
+	}
+	return
+}
+
+// posixRename renames the old to the new and replaces the new atomically when it exists.
+func (this *SecureFtp) posixRename(old string, new string) (err error) {
+	old = this.resolve(old)
+	new = this.resolve(new)
+	if err = this.requireExtension("posix-rename@openssh.com"); err != nil {
+		return
+	}
+	if this.params.dryRun {
+		this.logDryRun("rename", "path", old, "to", new)
+		return
+	}
+	if err = this.sftpClient.PosixRename(old, new); err != nil {
+		err = fmt.Errorf(`Rename "%v" to "%v": %w`, old, new, err)
+	}
+	return
+}
+
+// fsync flushes the remote file f to the disk of the server, the server lacking the extension is only logged.
+func (this *SecureFtp) fsync(f *sftp.File) (err error) {
+	if !this.hasExtension("fsync@openssh.com") {
+		if logger := this.params.logger; logger != nil {
+			logger(LogWarn, "fsync not supported", "host", this.params.host, "remote", f.Name())
+		}
+		return
+	}
+	if err = f.Sync(); err != nil {
+		err = fmt.Errorf(`Fsync "%v": %w`, f.Name(), err)
+	}
+	return
+}
+
+// link creates the newname as a hard link to the oldname.
+func (this *SecureFtp) link(oldname string, newname string) (err error) {
+	oldname = this.resolve(oldname)
+	newname = this.resolve(newname)
+	if err = this.requireExtension("hardlink@openssh.com"); err != nil {
+		return
+	}
+	if err = this.sftpClient.Link(oldname, newname); err != nil {
+		err = fmt.Errorf(`Link "%v" to "%v": %w`, newname, oldname, err)
+	}
+	return
+}
+
+func (this *SecureFtp) statVFS(p string) (stat *sftp.StatVFS, err error) {
+	p = this.resolve(p)
+	if err = this.requireExtension("statvfs@openssh.com"); err != nil {
+		return
+	}
+	if stat, err = this.sftpClient.StatVFS(p); err != nil {
+		err = fmt.Errorf(`StatVFS "%v": %w`, p, err)
+	}
+	return
+}
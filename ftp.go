@@ -39,6 +39,10 @@ func (this *Ftp) connect() (res *FtpResponse, err error) {
 	if ipaddr, err = net.LookupIP(this.params.host); err != nil {
 		return
 	}
+	if len(ipaddr) == 0 {
+		err = fmt.Errorf(`No addresses found for host "%v".`, this.params.host)
+		return
+	}
 
 	addr := fmt.Sprintf("%s:%d", ipaddr[0], this.params.port)
 
@@ -322,6 +326,10 @@ func (this *Ftp) pasv() (res *FtpResponse, dataConn net.Conn, err error) {
 	if ip, err = net.LookupIP(this.params.host); err != nil {
 		return
 	}
+	if len(ip) == 0 {
+		err = fmt.Errorf(`No addresses found for host "%v".`, this.params.host)
+		return
+	}
 	reg := regexp.MustCompile("([0-9]+?),([0-9]+?),([0-9]+?),([0-9]+?),([0-9]+?),([0-9]+)")
 	matches := reg.FindAllStringSubmatch(res.msg, -1)
 	tmp := matches[0]
@@ -611,7 +619,12 @@ func (this *Ftp) fileTransfer(direction int, uri interface{}, itf interface{}) (
 	if direction == DOWNLOAD {
 		var ok bool
 		if w, ok = uri.(io.WriteCloser); !ok {
-			if w, err = os.Create(uri.(string)); err != nil {
+			var name string
+			if name, ok = uri.(string); !ok {
+				err = fmt.Errorf("Unsupported local type %T, it must be the io.WriteCloser or the path of the file.", uri)
+				return
+			}
+			if w, err = os.Create(name); err != nil {
 				return
 			}
 		}
@@ -619,7 +632,12 @@ func (this *Ftp) fileTransfer(direction int, uri interface{}, itf interface{}) (
 	} else if direction == UPLOAD {
 		var ok bool
 		if r, ok = uri.(io.ReadCloser); !ok {
-			if r, err = os.Open(uri.(string)); err != nil {
+			var name string
+			if name, ok = uri.(string); !ok {
+				err = fmt.Errorf("Unsupported local type %T, it must be the io.ReadCloser or the path of the file.", uri)
+				return
+			}
+			if r, err = os.Open(name); err != nil {
 				return
 			}
 		}
@@ -0,0 +1,20 @@
+package sftps
+
+// Logger receives the events of the SFTP client, the kv are the alternating keys and values describing the event
+// (e.g. "host", "example.com", "bytes", 1024).
+type Logger func(level string, msg string, kv ...interface{})
+
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// WithLogger calls the logger on the dial, the auth methods attempted, each file transferred and the errors.
+// Nothing is logged when the logger is nil, the events are not even built so it costs nothing.
+func WithLogger(logger Logger) Option {
+	return func(param *sftpParameters) {
+		param.logger = logger
+	}
+}
@@ -0,0 +1,294 @@
+package sftps
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// Option configures the parameters of the SFTP client.
+type Option func(param *sftpParameters)
+
+// NewSftpClient creates the SFTP client for the host configured by the opts, e.g.
+//
+//	client, err := sftps.NewSftpClient("example.com", sftps.WithUser("user"), sftps.WithPassword("password"))
+//
+// At least one authentication method must be provided. The connection is established by Connect.
+func NewSftpClient(host string, opts ...Option) (client *Sftps, err error) {
+	param := Config{Host: host, Port: 22}.build()
+	param.Apply(opts...)
+	if err = param.validate(); err != nil {
+		return
+	}
+	if param.jumpHost != nil {
+		if err = param.jumpHost.validate(); err != nil {
+			return
+		}
+	}
+	if err = param.validateAuth(); err != nil {
+		return
+	}
+	client, err = New(SFTP, param)
+	return
+}
+
+// Apply applies the opts to the parameters created by NewSftpParameters.
+func (param *sftpParameters) Apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(param)
+	}
+}
+
+func (param *sftpParameters) hasAuth() bool {
+	return len(param.pass) > 0 || param.passwordCallback != nil || len(param.keys) > 0 || param.useAgent || param.challenge != nil
+}
+
+func WithPort(port int) Option {
+	return func(param *sftpParameters) {
+		param.port = port
+	}
+}
+
+func WithUser(user string) Option {
+	return func(param *sftpParameters) {
+		param.user = user
+	}
+}
+
+func WithPassword(pass string) Option {
+	return func(param *sftpParameters) {
+		param.pass = pass
+	}
+}
+
+// WithPrivateKeyReader adds the private key read from the key at the connect, e.g. a streaming secrets source,
+// so the key is not held as a string by the parameters. The passphrase decrypts the key when not nil.
+// The readers are read once, the parsed key is reused by the reconnection and the other connections of the Pool.
+func WithPrivateKeyReader(key io.Reader, passphrase io.Reader) Option {
+	return func(param *sftpParameters) {
+		param.keys = append(param.keys, &sftpKey{
+			reader:           key,
+			usePassphrase:    passphrase != nil,
+			passphraseReader: passphrase,
+		})
+	}
+}
+
+// WithPasswordCallback fetches the password by the callback on each connection attempt, e.g. from a secrets manager,
+// so the password is not held by the parameters. It takes the place of the password given by the WithPassword.
+func WithPasswordCallback(callback func() (string, error)) Option {
+	return func(param *sftpParameters) {
+		param.passwordCallback = callback
+	}
+}
+
+// WithPrivateKey adds the private key, the content or the path prefixed with "file:///".
+// The passphrase decrypts the key when not empty, it can be given repeatedly to offer several keys.
+func WithPrivateKey(privateKey string, passphrase string) Option {
+	return func(param *sftpParameters) {
+		param.Keys(privateKey, passphrase != "", passphrase)
+	}
+}
+
+// WithAgent authenticates with the SSH agent listening on the socket, or on SSH_AUTH_SOCK when it is empty.
+func WithAgent(socket string) Option {
+	return func(param *sftpParameters) {
+		param.Agent(socket)
+	}
+}
+
+func WithKeyboardInteractive(challenge ssh.KeyboardInteractiveChallenge) Option {
+	return func(param *sftpParameters) {
+		param.KeyboardInteractive(challenge)
+	}
+}
+
+func WithKnownHosts(file string) Option {
+	return func(param *sftpParameters) {
+		param.KnownHosts(file)
+	}
+}
+
+// WithInsecureSkipHostKeyCheck accepts any host key, NOT recommended.
+func WithInsecureSkipHostKeyCheck() Option {
+	return func(param *sftpParameters) {
+		param.InsecureSkipHostKeyCheck()
+	}
+}
+
+// WithTimeout bounds the TCP dial and the SSH handshake.
+func WithTimeout(timeout time.Duration) Option {
+	return func(param *sftpParameters) {
+		param.Timeout(timeout)
+	}
+}
+
+// WithKeepAlive keeps the connection open between the operations.
+func WithKeepAlive(keepAlive bool) Option {
+	return func(param *sftpParameters) {
+		param.keepAlive = keepAlive
+	}
+}
+
+func WithAtomicUpload() Option {
+	return func(param *sftpParameters) {
+		param.AtomicUpload()
+	}
+}
+
+// WithJumpHost tunnels the connection through the bastion host configured by the opts.
+func WithJumpHost(host string, opts ...Option) Option {
+	return func(param *sftpParameters) {
+		jump := Config{Host: host, Port: 22}.build()
+		jump.Apply(opts...)
+		param.JumpHost(jump)
+	}
+}
+
+// WithKeepAliveInterval sends the SSH keepalive request at the interval to prevent the idle disconnection, defaults to 30s.
+// Zero disables it. The connection is closed when the server does not reply within the interval.
+func WithKeepAliveInterval(interval time.Duration) Option {
+	return func(param *sftpParameters) {
+		param.keepAliveInterval = interval
+	}
+}
+
+// WithRateLimit caps the rate of each upload and download in bytes per second, zero means unlimited.
+func WithRateLimit(bytesPerSec int64) Option {
+	return func(param *sftpParameters) {
+		param.rateLimit = bytesPerSec
+	}
+}
+
+// WithMaxPacket sets the maximum size in bytes of the data carried by an SFTP packet, defaults to 32768.
+// The larger packets need fewer round trips, but the servers may reject the packets above 32768 (OpenSSH accepts up to 262144)
+// and each in-flight request buffers a packet, so the memory of a transfer is about the packet size times the concurrent requests.
+func WithMaxPacket(n int) Option {
+	return func(param *sftpParameters) {
+		param.maxPacket = n
+	}
+}
+
+// WithMaxConcurrentRequests sets the number of the requests in flight per file, defaults to 64 for the reads only.
+// More than one enables both the concurrent reads and writes which fill the bandwidth of the high latency links,
+// one disables them. The concurrent writes may leave a hole in the remote file when a write failed midway,
+// so the failed upload must be discarded rather than resumed.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(param *sftpParameters) {
+		param.maxConcurrentRequests = n
+	}
+}
+
+// WithSOCKS5Proxy establishes the TCP connection through the SOCKS5 proxy at the addr ("host:port"),
+// the user and the password are sent only when the user is not empty. The proxy resolves the host of the server,
+// and with the jump host only the connection to the jump host goes through the proxy. An empty addr dials directly.
+func WithSOCKS5Proxy(addr string, user string, password string) Option {
+	return func(param *sftpParameters) {
+		param.proxyAddr = addr
+		param.proxyAuth = nil
+		if user != "" {
+			param.proxyAuth = &proxy.Auth{User: user, Password: password}
+		}
+	}
+}
+
+// WithHostKeyAlgorithms sets the host key algorithms accepted from the server in the order of the preference,
+// e.g. []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA} for a legacy server offering the RSA key only.
+// The valid algorithms are "ssh-ed25519", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521",
+// "rsa-sha2-512", "rsa-sha2-256", "ssh-rsa" and their certificate variants (ssh.CertAlgoED25519v01 etc.).
+// The empty algos keep the defaults of the x/crypto/ssh.
+func WithHostKeyAlgorithms(algos []string) Option {
+	return func(param *sftpParameters) {
+		param.hostKeyAlgorithms = algos
+	}
+}
+
+// WithCiphers sets the ciphers in the order of the preference, e.g. "aes128-gcm@openssh.com" or "aes256-ctr".
+// The empty ciphers keep the secure defaults of the x/crypto/ssh.
+func WithCiphers(ciphers []string) Option {
+	return func(param *sftpParameters) {
+		param.ciphers = ciphers
+	}
+}
+
+// WithKeyExchanges sets the key exchange algorithms in the order of the preference, e.g. "curve25519-sha256" or "diffie-hellman-group14-sha256".
+// The empty algos keep the secure defaults of the x/crypto/ssh.
+func WithKeyExchanges(algos []string) Option {
+	return func(param *sftpParameters) {
+		param.keyExchanges = algos
+	}
+}
+
+// WithMACs sets the message authentication codes in the order of the preference, e.g. "hmac-sha2-256-etm@openssh.com" or "hmac-sha2-256".
+// The empty macs keep the secure defaults of the x/crypto/ssh.
+func WithMACs(macs []string) Option {
+	return func(param *sftpParameters) {
+		param.macs = macs
+	}
+}
+
+// WithNetwork sets the network of the TCP connections from the client, "tcp4" for IPv4 only, "tcp6" for IPv6 only
+// and "tcp" (the default) for both. The connection from the jump host to the server is left to the jump host.
+// The NewSftpClient returns the error for any other network.
+func WithNetwork(network string) Option {
+	return func(param *sftpParameters) {
+		param.network = network
+	}
+}
+
+// WithDryRun makes the Rmdir, RemoveAll, Rename, PosixRename and Sync log the intended changes by the logger
+// and succeed without performing them, the Sync still reports what it would upload and delete.
+func WithDryRun(dryRun bool) Option {
+	return func(param *sftpParameters) {
+		param.dryRun = dryRun
+	}
+}
+
+// WithNoClobber makes the uploads fail with the ErrExists instead of overwriting the existing remote file,
+// it applies to the Upload and its variants but not to the UploadDir and the Sync.
+// With the WithAtomicUpload the temporary file is moved into place without overwriting, so the file created meanwhile is kept.
+func WithNoClobber(noClobber bool) Option {
+	return func(param *sftpParameters) {
+		param.noClobber = noClobber
+	}
+}
+
+// WithFsync makes the uploads flush the remote file to the disk of the server before closing it,
+// so that the uploaded data survives a crash of the server. The flush needs the fsync@openssh.com extension,
+// the servers not advertising it are only logged with the LogWarn and the upload goes on without the flush.
+func WithFsync(fsync bool) Option {
+	return func(param *sftpParameters) {
+		param.fsync = fsync
+	}
+}
+
+// WithStallTimeout aborts the transfer with the ErrStalled once no byte moved for the timeout,
+// e.g. the hung server whose connection still answers the keepalives. The zero disables the watchdog.
+// It watches every SFTP copy of the file content: the uploads and the downloads including the Overwrite, the Append,
+// the ResumeDownload, the DownloadTo, the ReadFile, the Copy, the directory transfers, the Sync and the Pull,
+// and each chunk of the DownloadConcurrent. The tar transfers and the FTP protocol are not watched.
+// The stall closes the connection, it is established again by the reconnect policy or the Reconnect.
+func WithStallTimeout(timeout time.Duration) Option {
+	return func(param *sftpParameters) {
+		param.stallTimeout = timeout
+	}
+}
+
+// WithListTimeout bounds the "ls" command the List runs in the remote shell, the session is closed when the command
+// does not finish in time. The zero keeps the default of the TIMEOUT, the connect timeout does not apply to the command.
+func WithListTimeout(timeout time.Duration) Option {
+	return func(param *sftpParameters) {
+		param.listTimeout = timeout
+	}
+}
+
+// WithCopyBufferSize sets the size of the buffer the transfers are copied through, the larger buffer lets
+// each read or write issue more concurrent requests which pays off over the high-latency links.
+// The zero keeps the default copy of the *sftp.File by its WriteTo and ReadFrom.
+func WithCopyBufferSize(n int) Option {
+	return func(param *sftpParameters) {
+		param.copyBufferSize = n
+	}
+}
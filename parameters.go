@@ -1,5 +1,16 @@
 package sftps
 
+import (
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
 type ftpParameters struct {
 	host        string
 	port        int
@@ -16,46 +27,163 @@ type ftpParameters struct {
 	key         string
 }
 
-type sftpParameters struct {
-	host          string
-	port          int
-	user          string
-	pass          string
-	useKey        bool
+type sftpKey struct {
 	privateKey    string
 	usePassphrase bool
 	passphrase    string
-	keepAlive     bool
+	// The readers supply the key and the passphrase at the first connect instead of the strings above,
+	// the parsed signer and its error are kept for the reconnection and the other connections of the Pool
+	// since the readers can not be read again. The once keeps the concurrent connects from reading them together.
+	reader           io.Reader
+	passphraseReader io.Reader
+	once             sync.Once
+	signer           ssh.Signer
+	err              error
+}
+
+// readerSigner parses the key read from the readers at its first call and returns the same result afterward.
+func (key *sftpKey) readerSigner() (ssh.Signer, error) {
+	key.once.Do(func() {
+		key.signer, key.err = readSigner(key)
+	})
+	return key.signer, key.err
+}
+
+type sftpParameters struct {
+	host         string
+	port         int
+	user         string
+	pass         string
+	keys         []*sftpKey
+	keepAlive    bool
+	knownHosts   string
+	insecure     bool
+	jumpHost     *sftpParameters
+	timeout      time.Duration
+	useAgent     bool
+	agentSocket  string
+	challenge    ssh.KeyboardInteractiveChallenge
+	atomicUpload bool
+	reconnect    *RetryPolicy
+	// keepAliveInterval is the period of the SSH keepalive requests, not to be confused with the keepAlive of the connection.
+	keepAliveInterval  time.Duration
+	rateLimit          int64
+	verifyByRedownload bool
+	logger             Logger
+	// maxPacket and maxConcurrentRequests tune the SFTP client, zero keeps the defaults of the pkg/sftp.
+	maxPacket             int
+	maxConcurrentRequests int
+	proxyAddr             string
+	proxyAuth             *proxy.Auth
+	hostKeyAlgorithms     []string
+	ciphers               []string
+	keyExchanges          []string
+	macs                  []string
+	passwordCallback      func() (string, error)
+	network               string
+	dryRun                bool
+	noClobber             bool
+	copyBufferSize        int
+	fsync                 bool
+	stallTimeout          time.Duration
+	listTimeout           time.Duration
 }
 
 func NewSftpParameters(host string, port int, user string, pass string, keepAlive bool) *sftpParameters {
-	if host == "" || user == "" {
-		panic("Invalid parameter were bound.")
+	cfg := Config{
+		Host:      host,
+		Port:      port,
+		User:      user,
+		Password:  pass,
+		KeepAlive: keepAlive,
 	}
-	param := &sftpParameters{
-		host:          host,
-		port:          port,
-		user:          user,
-		pass:          pass,
-		useKey:        false,
-		privateKey:    "",
-		usePassphrase: false,
-		passphrase:    "",
-		keepAlive:     keepAlive,
+	param, err := cfg.parameters()
+	if err != nil {
+		panic("Invalid parameter were bound.")
 	}
 	return param
 }
 
+// sshPort returns the port of the server, 22 when the port is not specified.
+func (param *sftpParameters) sshPort() int {
+	if param.port == 0 {
+		return 22
+	}
+	return param.port
+}
+
+func (param *sftpParameters) hostPort() string {
+	return net.JoinHostPort(param.host, strconv.Itoa(param.sshPort()))
+}
+
+// tcpNetwork returns the network of the TCP connections, "tcp" when it is unset.
+func (param *sftpParameters) tcpNetwork() string {
+	if len(param.network) == 0 {
+		return "tcp"
+	}
+	return param.network
+}
+
+// Keys adds the private key used for the authentication, it can be called repeatedly to offer several keys.
+// The server picks whichever of the keys it trusts.
 func (param *sftpParameters) Keys(privateKey string, usePassphrase bool, passphrase string) {
-	param.privateKey = privateKey
-	param.useKey = true
+	key := &sftpKey{
+		privateKey:    privateKey,
+		usePassphrase: false,
+		passphrase:    "",
+	}
 	if usePassphrase {
 		if passphrase == "" {
 			panic("The passphrase must not be empty when specified true to usePassphrase.")
 		}
-		param.usePassphrase = true
-		param.passphrase = passphrase
+		key.usePassphrase = true
+		key.passphrase = passphrase
 	}
+	param.keys = append(param.keys, key)
+}
+
+// KnownHosts specifies the OpenSSH known_hosts file used to verify the host key of the server.
+// When not specified, "~/.ssh/known_hosts" is used.
+func (param *sftpParameters) KnownHosts(file string) {
+	param.knownHosts = file
+}
+
+// InsecureSkipHostKeyCheck accepts any host key presented by the server.
+// This makes the connection vulnerable to man-in-the-middle attacks and must be chosen consciously.
+func (param *sftpParameters) InsecureSkipHostKeyCheck() {
+	param.insecure = true
+}
+
+// Agent authenticates with the keys held by the SSH agent listening on the socket,
+// the SSH_AUTH_SOCK environment variable is used when the socket is empty.
+func (param *sftpParameters) Agent(socket string) {
+	param.useAgent = true
+	param.agentSocket = socket
+}
+
+// KeyboardInteractive enables the keyboard-interactive authentication (e.g. OTP/2FA),
+// the challenge receives the prompts from the server and returns the answers of the user.
+func (param *sftpParameters) KeyboardInteractive(challenge ssh.KeyboardInteractiveChallenge) {
+	param.challenge = challenge
+}
+
+// AtomicUpload uploads to the temporary file named "[remote].part" and renames it to the remote only after the copy succeeded,
+// the temporary file is removed on failure.
+func (param *sftpParameters) AtomicUpload() {
+	param.atomicUpload = true
+}
+
+// JumpHost specifies the bastion server that the connection to the final host is tunneled through.
+// The jump parameter is created by NewSftpParameters, its keepAlive is ignored.
+func (param *sftpParameters) JumpHost(jump *sftpParameters) {
+	param.jumpHost = jump
+}
+
+// Timeout bounds each step of the connection to the server on its own, the TCP dial of each address and the SSH handshake,
+// not the subsequent transfers.
+// Zero means no timeout.
+func (param *sftpParameters) Timeout(timeout time.Duration) {
+	param.timeout = timeout
 }
 
 func NewFtpParameters(host string, port int, user string, pass string, keepalive bool) *ftpParameters {
@@ -0,0 +1,15 @@
+package sftps
+
+import "testing"
+
+func TestSshPort(t *testing.T) {
+	if port := (&sftpParameters{}).sshPort(); port != 22 {
+		t.Errorf("sshPort of the unset port = %d, want 22", port)
+	}
+	if port := (&sftpParameters{port: 2222}).sshPort(); port != 2222 {
+		t.Errorf("sshPort = %d, want 2222", port)
+	}
+	if hostPort := (&sftpParameters{host: "::1"}).hostPort(); hostPort != "[::1]:22" {
+		t.Errorf("hostPort = %q, want %q", hostPort, "[::1]:22")
+	}
+}
@@ -0,0 +1,150 @@
+package sftps
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by the Get of the closed Pool.
+var ErrPoolClosed = errors.New("The pool is closed.")
+
+// PoolOptions controls the connections kept by the Pool.
+type PoolOptions struct {
+	// MaxIdle is the number of the idle connections kept for the reuse, defaults to 2. A negative value keeps none.
+	MaxIdle int
+	// MaxOpen is the number of the connections open at once including the ones in use, the Get blocks when it is reached.
+	// Zero means unlimited.
+	MaxOpen int
+	// IdleTimeout closes the idle connections unused for the duration, zero keeps them forever.
+	IdleTimeout time.Duration
+}
+
+type idleConn struct {
+	client *Sftps
+	since  time.Time
+}
+
+// Pool keeps the SFTP connections for the reuse so that each operation does not pay for the SSH handshake.
+// The idle connection is validated with the Ping before it is returned, the dead one is replaced by a new connection.
+// It is safe for the concurrent use.
+type Pool struct {
+	param *sftpParameters
+	opts  PoolOptions
+	// sem holds a token for each open connection, it is nil when the MaxOpen is unlimited.
+	sem    chan struct{}
+	mu     sync.Mutex
+	idle   []*idleConn
+	closed bool
+}
+
+// NewPool creates the Pool connecting with the param, the connections are kept alive regardless of the keepalive of the param.
+func NewPool(param *sftpParameters, opts PoolOptions) (pool *Pool) {
+	if opts.MaxIdle == 0 {
+		opts.MaxIdle = 2
+	}
+	pool = &Pool{
+		param: param,
+		opts:  opts,
+	}
+	if opts.MaxOpen > 0 {
+		pool.sem = make(chan struct{}, opts.MaxOpen)
+	}
+	return
+}
+
+// Get returns an idle connection which is alive or connects a new one, the connection must be returned by the Put.
+func (this *Pool) Get() (client *Sftps, err error) {
+	if this.sem != nil {
+		this.sem <- struct{}{}
+	}
+	for {
+		this.mu.Lock()
+		if this.closed {
+			this.mu.Unlock()
+			this.release()
+			err = ErrPoolClosed
+			return
+		}
+		n := len(this.idle)
+		if n == 0 {
+			this.mu.Unlock()
+			break
+		}
+		conn := this.idle[n-1]
+		this.idle = this.idle[:n-1]
+		this.mu.Unlock()
+
+		if this.expired(conn) || conn.client.Ping() != nil {
+			conn.client.Quit()
+			continue
+		}
+		client = conn.client
+		return
+	}
+	if client, err = this.connect(); err != nil {
+		this.release()
+	}
+	return
+}
+
+// Put returns the client got by the Get to the pool, it is closed when the pool has the MaxIdle connections or is closed.
+func (this *Pool) Put(client *Sftps) {
+	if client == nil {
+		return
+	}
+	defer this.release()
+	this.mu.Lock()
+	if this.closed || len(this.idle) >= this.opts.MaxIdle {
+		this.mu.Unlock()
+		client.Quit()
+		return
+	}
+	// The oldest connections are at the head, the expired ones are closed instead of waiting for the Get.
+	var expired []*idleConn
+	for len(this.idle) > 0 && this.expired(this.idle[0]) {
+		expired = append(expired, this.idle[0])
+		this.idle = this.idle[1:]
+	}
+	this.idle = append(this.idle, &idleConn{client: client, since: time.Now()})
+	this.mu.Unlock()
+	for _, conn := range expired {
+		conn.client.Quit()
+	}
+}
+
+// Close closes the idle connections, the connections in use are closed when they are returned by the Put.
+func (this *Pool) Close() (err error) {
+	this.mu.Lock()
+	idle := this.idle
+	this.idle = nil
+	this.closed = true
+	this.mu.Unlock()
+	for _, conn := range idle {
+		if _, e := conn.client.Quit(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (this *Pool) connect() (client *Sftps, err error) {
+	if client, err = New(SFTP, this.param); err != nil {
+		return
+	}
+	client.keepalive = true
+	if _, err = client.Connect(); err != nil {
+		client = nil
+	}
+	return
+}
+
+func (this *Pool) expired(conn *idleConn) bool {
+	return this.opts.IdleTimeout > 0 && time.Since(conn.since) > this.opts.IdleTimeout
+}
+
+func (this *Pool) release() {
+	if this.sem != nil {
+		<-this.sem
+	}
+}
@@ -0,0 +1,40 @@
+package sftps
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestPoolConcurrentGet(t *testing.T) {
+	port := newTestServer(t)
+	// The connections of the pool share the parameters and the key reader, which is read only once.
+	param := NewSftpParameters("127.0.0.1", port, testUser, "", true)
+	param.InsecureSkipHostKeyCheck()
+	param.Apply(WithPrivateKeyReader(bytes.NewReader(testClientKey(t)), nil))
+	pool := NewPool(param, PoolOptions{MaxOpen: 8})
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := pool.Get()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer pool.Put(client)
+			if _, err = client.Getwd(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
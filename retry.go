@@ -0,0 +1,83 @@
+package sftps
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// RetryPolicy controls the automatic reconnection of the SFTP client when an operation failed because the connection is dead.
+type RetryPolicy struct {
+	// MaxAttempts is the number of the reconnections tried for an operation.
+	MaxAttempts int
+	// Backoff is the wait before each reconnection.
+	Backoff time.Duration
+	// Exponential doubles the Backoff after each attempt.
+	Exponential bool
+	// Retryable reports whether the operation failed with the err is retried, defaults to IsTransient.
+	// It is only consulted for the operations which are safe to repeat, see WithReconnect.
+	Retryable func(err error) bool
+}
+
+// retryable reports whether the err is retried by the policy.
+func (policy *RetryPolicy) retryable(err error) bool {
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// backoff returns the wait before the attempt counted from zero.
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	if !policy.Exponential {
+		return policy.Backoff
+	}
+	return policy.Backoff << uint(attempt)
+}
+
+// WithReconnect re-dials the server with the stored parameters and retries the operation
+// when it failed with the error accepted by the policy.Retryable, by default when the connection is dead
+// (e.g. io.EOF or use of the closed connection) or timed out.
+//
+// The retried operation is replayed from the start. The operations which can not be repeated after they failed midway
+// are never retried: the Append, the Run, the UploadReader, the UploadFrom and the DownloadTo, as well as the uploads
+// and the downloads whose local is an io.Reader or an io.Writer rather than the path of a file. The other operations,
+// including the uploads and the downloads of the local files, are replayed.
+func WithReconnect(policy RetryPolicy) Option {
+	return func(param *sftpParameters) {
+		param.reconnect = &policy
+	}
+}
+
+// WithRetry reconnects and retries the whole operation up to the attempts when it failed with a transient error,
+// the wait starts at the backoff and doubles after each attempt. The permanent errors such as the denied permission are not retried.
+// The ResumeDownload continues from the bytes already downloaded on each attempt, the other transfers restart.
+// The operations which can not be repeated are not retried, see WithReconnect.
+// Set the RetryPolicy.Retryable by the WithReconnect to replace the classification of the transient errors.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return WithReconnect(RetryPolicy{MaxAttempts: attempts, Backoff: backoff, Exponential: true})
+}
+
+// IsTransient reports whether the err is likely to succeed on the retry, i.e. the connection is lost or timed out.
+func IsTransient(err error) bool {
+	if isConnectionLost(err) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isConnectionLost reports whether the err indicates that the connection is dead.
+func isConnectionLost(err error) bool {
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
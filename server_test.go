@@ -0,0 +1,240 @@
+package sftps
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testUser     = "user"
+	testPassword = "pass"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKeyPEM  []byte
+	testKey     ssh.PublicKey
+)
+
+// testClientKey returns the PEM of the private key the newTestServer accepts for the testUser.
+func testClientKey(t testing.TB) []byte {
+	t.Helper()
+	testKeyOnce.Do(func() {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return
+		}
+		block, err := ssh.MarshalPrivateKey(key, "")
+		if err != nil {
+			return
+		}
+		signer, err := ssh.NewSignerFromKey(key)
+		if err != nil {
+			return
+		}
+		testKeyPEM = pem.EncodeToMemory(block)
+		testKey = signer.PublicKey()
+	})
+	if testKeyPEM == nil {
+		t.Fatal("the client key could not be generated")
+	}
+	return testKeyPEM
+}
+
+// newTestServer starts the in-process SSH server with the SFTP subsystem serving the local file system,
+// it accepts the testUser with the testPassword or the testClientKey and returns the port it listens on.
+func newTestServer(t testing.TB) (port int) {
+	t.Helper()
+	return startTestServer(t, serveTestSftp)
+}
+
+func serveTestSftp(ch ssh.Channel) {
+	server, err := sftp.NewServer(ch)
+	if err != nil {
+		return
+	}
+	server.Serve()
+	server.Close()
+}
+
+// newExecTestServer starts the in-process SSH server like the newTestServer which also runs the commands by the exec,
+// the exec writes the output to the ch and returns the exit status.
+func newExecTestServer(t testing.TB, exec func(cmd string, ch ssh.Channel) (status uint32)) (port int) {
+	t.Helper()
+	return startTestServerExec(t, serveTestSftp, exec)
+}
+
+// newHandlersTestServer starts the in-process SSH server like the newTestServer but the SFTP requests are served by the handlers,
+// so that the tests can stage the failures the local file system does not produce.
+func newHandlersTestServer(t testing.TB, handlers sftp.Handlers) (port int) {
+	t.Helper()
+	return startTestServer(t, func(ch ssh.Channel) {
+		server := sftp.NewRequestServer(ch, handlers)
+		server.Serve()
+		server.Close()
+	})
+}
+
+// deniedLister refuses to list or stat the files named denied, the other files are listed by the FileLister.
+type deniedLister struct {
+	sftp.FileLister
+}
+
+func (l deniedLister) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if path.Base(r.Filepath) == "denied" {
+		// The server reports the EACCES as the SSH_FX_PERMISSION_DENIED, the os.ErrPermission as the plain failure.
+		return nil, syscall.EACCES
+	}
+	return l.FileLister.Filelist(r)
+}
+
+// failingReader serves the reads of the FileReader until the offset after, the reads beyond it fail like a broken disk.
+// With the stall the reads beyond the after hang like a hung server until the stall is closed.
+type failingReader struct {
+	sftp.FileReader
+	after int64
+	stall <-chan struct{}
+}
+
+func (f failingReader) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	ra, err := f.FileReader.Fileread(r)
+	if err != nil {
+		return nil, err
+	}
+	return failingReaderAt{r: ra, after: f.after, stall: f.stall}, nil
+}
+
+type failingReaderAt struct {
+	r     io.ReaderAt
+	after int64
+	stall <-chan struct{}
+}
+
+func (f failingReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off+int64(len(p)) > f.after {
+		if f.stall != nil {
+			<-f.stall
+		}
+		return 0, syscall.EIO
+	}
+	return f.r.ReadAt(p, off)
+}
+
+func startTestServer(t testing.TB, serve func(ch ssh.Channel)) (port int) {
+	t.Helper()
+	return startTestServerExec(t, serve, nil)
+}
+
+func startTestServerExec(t testing.TB, serve func(ch ssh.Channel), exec func(cmd string, ch ssh.Channel) (status uint32)) (port int) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == testUser && string(pass) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("password rejected")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == testUser && testKey != nil && bytes.Equal(key.Marshal(), testKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("public key rejected")
+		},
+	}
+	config.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(conn, config, serve, exec)
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func serveTestConn(conn net.Conn, config *ssh.ServerConfig, serve func(ch ssh.Channel), exec func(cmd string, ch ssh.Channel) (status uint32)) {
+	sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range requests {
+				// The payload of the subsystem and the exec requests is the length-prefixed name of the subsystem or the command.
+				if req.Type == "exec" && exec != nil && len(req.Payload) > 4 {
+					req.Reply(true, nil)
+					status := exec(string(req.Payload[4:]), ch)
+					ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+					return
+				}
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				serve(ch)
+				return
+			}
+		}()
+	}
+}
+
+// newTestClient connects to the newTestServer listening on the port, the connection is kept alive and closed by the cleanup.
+func newTestClient(t testing.TB, port int, opts ...Option) *Sftps {
+	t.Helper()
+	param := NewSftpParameters("127.0.0.1", port, testUser, testPassword, true)
+	param.InsecureSkipHostKeyCheck()
+	param.Apply(opts...)
+	client, err := New(SFTP, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Quit()
+	})
+	return client
+}
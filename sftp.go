@@ -1,22 +1,38 @@
 package sftps
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
 )
 
 type SecureFtp struct {
 	sshClient  *ssh.Client
 	sftpClient *sftp.Client
-	params     *sftpParameters
-	state      int
+	jumpClient *ssh.Client
+	// stopKeepAlive stops the goroutine sending the keepalive requests.
+	stopKeepAlive chan struct{}
+	params        *sftpParameters
+	state         int
+	// cwd is the absolute directory the relative remote paths are resolved against, empty leaves them to the server.
+	cwd string
 }
 
 func newSftp(p *sftpParameters) (sftp *SecureFtp) {
@@ -26,175 +42,1240 @@ func newSftp(p *sftpParameters) (sftp *SecureFtp) {
 }
 
 func (this *SecureFtp) connect() (err error) {
+	return this.connectContext(context.Background())
+}
+
+// connectContext honors the deadline and the cancellation of ctx throughout the DNS resolution and the TCP/SSH handshake.
+// The timeout of the params bounds each step on its own: the resolution, each dial attempt, the SSH handshake and
+// the start of the SFTP session.
+func (this *SecureFtp) connectContext(ctx context.Context) (err error) {
+	return this.establish(ctx, this.dialServer)
+}
+
+// connectOverConn runs the SSH handshake over the conn provided by the caller instead of dialing the server,
+// the jump host is not used. The host is still required to match the known_hosts file.
+func (this *SecureFtp) connectOverConn(conn net.Conn) (err error) {
+	return this.establish(context.Background(), func(ctx context.Context) (net.Conn, error) {
+		return conn, nil
+	})
+}
+
+// establish runs the SSH handshake and starts the SFTP session over the conn returned by the dial.
+func (this *SecureFtp) establish(ctx context.Context, dial func(ctx context.Context) (net.Conn, error)) (err error) {
+	var conn net.Conn
+	defer func() {
+		err = classifyConnect(this.params.host, err)
+		if logger := this.params.logger; logger != nil {
+			if err != nil {
+				logger(LogError, "connect failed", "host", this.params.host, "error", err)
+			} else {
+				logger(LogInfo, "connected", "host", this.params.host, "server", string(this.sshClient.ServerVersion()))
+			}
+		}
+	}()
+
+	if conn, err = dial(ctx); err != nil {
+		return
+	}
+	if this.sshClient, err = this.handshake(ctx, conn, this.params); err != nil {
+		this.closeJumpHost()
+		return
+	}
+	sessionCtx, cancel := withTimeout(ctx, this.params.timeout)
+	defer cancel()
+	stop := closeOnDone(sessionCtx, this.sshClient)
+	this.sftpClient, err = sftp.NewClient(this.sshClient, this.params.sftpOptions()...)
+	if e := stop(); e != nil && err == nil {
+		this.sftpClient.Close()
+		err = e
+	}
+	// The failure of the SFTP session is always reported, the error of closing the SSH connection is secondary.
+	if err != nil {
+		err = fmt.Errorf("SFTP session: %w", err)
+		if e := this.sshClient.Close(); e != nil {
+			err = fmt.Errorf("%w (Close: %v)", err, e)
+		}
+		this.sftpClient = nil
+		this.sshClient = nil
+		this.closeJumpHost()
+		return
+	}
+	this.startKeepAlive()
+	return
+}
+
+// dialServer connects to the server, through the jump host when it is configured.
+func (this *SecureFtp) dialServer(ctx context.Context) (conn net.Conn, err error) {
+	jump := this.params.jumpHost
+	if jump == nil {
+		return this.dial(ctx, this.params)
+	}
+	if conn, err = this.dial(ctx, jump); err != nil {
+		return
+	}
+	if this.jumpClient, err = this.handshake(ctx, conn, jump); err != nil {
+		err = fmt.Errorf(`Jump Host "%v": %w`, jump.host, err)
+		return
+	}
+	// The final host is resolved by the jump host, it may not be reachable from here.
+	dialCtx, cancel := withTimeout(ctx, this.params.timeout)
+	defer cancel()
+	if conn, err = this.jumpClient.DialContext(dialCtx, "tcp", this.params.hostPort()); err != nil {
+		this.closeJumpHost()
+		err = fmt.Errorf(`Dial "%v" through the Jump Host "%v": %w`, this.params.host, jump.host, err)
+	}
+	return
+}
+
+// startKeepAlive sends the keepalive request periodically to prevent the server from dropping the idle connection.
+// When the server does not reply, the connection is closed so that the operations fail as the connection is lost.
+func (this *SecureFtp) startKeepAlive() {
+	interval := this.params.keepAliveInterval
+	if interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	this.stopKeepAlive = stop
+	client := this.sshClient
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			replied := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}()
+			select {
+			case <-stop:
+				return
+			case err := <-replied:
+				if err == nil {
+					continue
+				}
+			case <-time.After(interval):
+			}
+			client.Close()
+			return
+		}
+	}()
+}
+
+// dial attempts every resolved address of the host in order until one succeeds,
+// the error of the last attempt is returned when all of them fail.
+func (this *SecureFtp) dial(ctx context.Context, p *sftpParameters) (conn net.Conn, err error) {
+	if len(this.params.proxyAddr) > 0 {
+		return this.dialProxy(ctx, p)
+	}
 	var ip []net.IP
+	network := this.params.tcpNetwork()
+	// The "tcp4" and the "tcp6" resolve the addresses of their family only.
+	lookupCtx, cancel := withTimeout(ctx, p.timeout)
+	ip, err = net.DefaultResolver.LookupIP(lookupCtx, "ip"+strings.TrimPrefix(network, "tcp"), p.host)
+	cancel()
+	if err != nil {
+		return
+	}
+	// The resolver may answer with no address and no error, the loop below would return a nil conn then.
+	if len(ip) == 0 {
+		err = fmt.Errorf(`No addresses found for host "%v".`, p.host)
+		return
+	}
+	// The timeout bounds each address on its own, the blackholed address does not use up the time of the next ones.
+	dialer := new(net.Dialer)
+	dialer.Timeout = p.timeout
+	for _, addr := range ip {
+		address := net.JoinHostPort(addr.String(), strconv.Itoa(p.sshPort()))
+		if logger := p.logger; logger != nil {
+			logger(LogDebug, "dial", "host", p.host, "address", address)
+		}
+		if conn, err = dialer.DialContext(ctx, network, address); err == nil {
+			if logger := p.logger; logger != nil {
+				logger(LogDebug, "dialed", "host", p.host, "address", address)
+			}
+			return
+		}
+		if logger := p.logger; logger != nil {
+			logger(LogDebug, "dial failed", "host", p.host, "address", address, "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
 
-	config := &ssh.ClientConfig{
-		User: this.params.user,
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
+// dialProxy connects to the host of the p through the SOCKS5 proxy, the host is resolved by the proxy.
+func (this *SecureFtp) dialProxy(ctx context.Context, p *sftpParameters) (conn net.Conn, err error) {
+	forward := new(net.Dialer)
+	forward.Timeout = p.timeout
+	var dialer proxy.Dialer
+	network := this.params.tcpNetwork()
+	if dialer, err = proxy.SOCKS5(network, this.params.proxyAddr, this.params.proxyAuth, forward); err != nil {
+		err = fmt.Errorf(`SOCKS5 Proxy "%v": %w`, this.params.proxyAddr, err)
+		return
+	}
+	if logger := p.logger; logger != nil {
+		logger(LogDebug, "dial", "host", p.host, "proxy", this.params.proxyAddr)
+	}
+	if d, ok := dialer.(proxy.ContextDialer); ok {
+		conn, err = d.DialContext(ctx, network, p.hostPort())
+	} else {
+		conn, err = dialer.Dial(network, p.hostPort())
+	}
+	if err != nil {
+		err = fmt.Errorf(`Dial "%v" through the SOCKS5 Proxy "%v": %w`, p.host, this.params.proxyAddr, err)
+	}
+	return
+}
+
+// handshake establishes the SSH connection over conn, the conn is closed when the handshake fails.
+func (this *SecureFtp) handshake(ctx context.Context, conn net.Conn, p *sftpParameters) (client *ssh.Client, err error) {
+	var config *ssh.ClientConfig
+	var agentConn net.Conn
+	signers, keyErr := this.signers(p)
+	if config, agentConn, err = this.clientConfig(p, signers); err != nil {
+		conn.Close()
+		return
+	}
+	if agentConn != nil {
+		// The agent is only consulted during the authentication.
+		defer agentConn.Close()
+	}
+	// The host name is passed to the handshake so that the known_hosts entries recorded by the host name are matched.
+	var c ssh.Conn
+	var chans <-chan ssh.NewChannel
+	var reqs <-chan *ssh.Request
+	// The ssh.NewClientConn ignores the Timeout of the config, the handshake is bounded by the deadline of the conn.
+	if p.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+	stop := closeOnDone(ctx, conn)
+	c, chans, reqs, err = ssh.NewClientConn(conn, p.hostPort(), config)
+	if e := stop(); e != nil && err == nil {
+		c.Close()
+		err = e
+	}
+	if err == nil {
+		conn.SetDeadline(time.Time{})
 	}
+	if err != nil {
+		conn.Close()
+		if e := ctx.Err(); e != nil {
+			err = e
+		} else if keyErr != nil {
+			err = fmt.Errorf("%w (%v)", err, keyErr)
+		}
+		return
+	}
+	client = ssh.NewClient(c, chans, reqs)
+	return
+}
+
+// sftpOptions returns the options of the SFTP client tuned by the p.
+func (p *sftpParameters) sftpOptions() (opts []sftp.ClientOption) {
+	if p.maxPacket > 0 {
+		opts = append(opts, sftp.MaxPacket(p.maxPacket))
+	}
+	if n := p.maxConcurrentRequests; n > 0 {
+		opts = append(opts, sftp.UseConcurrentReads(n > 1), sftp.UseConcurrentWrites(n > 1), sftp.MaxConcurrentRequestsPerFile(n))
+	}
+	return
+}
+
+// logAuth logs the auth method attempted with the p.
+func (p *sftpParameters) logAuth(method string) {
+	if logger := p.logger; logger != nil {
+		logger(LogDebug, "auth", "host", p.host, "user", p.user, "method", method)
+	}
+}
 
-	if this.params.useKey {
+// logDryRun logs the action skipped by the dry run, the caller checks the dry run beforehand
+// so that the kv are not built otherwise.
+func (this *SecureFtp) logDryRun(action string, kv ...interface{}) {
+	if logger := this.params.logger; logger != nil {
+		logger(LogInfo, "dry run: "+action, kv...)
+	}
+}
+
+// logTransfer logs the transferred file with its byte count, or the error of the transfer.
+func (this *SecureFtp) logTransfer(op string, remote string, len int64, err error) {
+	if logger := this.params.logger; logger != nil {
+		if err != nil {
+			logger(LogError, op+" failed", "remote", remote, "bytes", len, "error", err)
+		} else {
+			logger(LogInfo, op, "remote", remote, "bytes", len)
+		}
+	}
+}
+
+// withTimeout is the context.WithTimeout leaving the ctx as it is when the timeout is not positive.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// closeOnDone closes c when ctx is done before the returned stop function is called.
+// The stop function returns the cause of ctx, the c must be treated as closed if it is not nil.
+// The c is not closed by the ctx done after the stop function returned, the stop does not wait for the Close
+// which itself may hang on the hung server.
+func closeOnDone(ctx context.Context, c io.Closer) (stop func() error) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-done:
+			default:
+				c.Close()
+			}
+		case <-done:
+		}
+	}()
+	return func() error {
+		close(done)
+		return context.Cause(ctx)
+	}
+}
+
+// signers parses every private key of p, the keys failed to parse are reported by the err
+// but do not prevent the remaining keys from being used.
+func (this *SecureFtp) signers(p *sftpParameters) (signers []ssh.Signer, err error) {
+	var errs []string
+	for _, key := range p.keys {
+		if key.reader != nil {
+			signer, e := key.readerSigner()
+			if e != nil {
+				errs = append(errs, fmt.Sprintf(`Private Key #%d: %v`, len(signers)+len(errs)+1, e))
+				continue
+			}
+			signers = append(signers, signer)
+			continue
+		}
 		var pemBytes []byte
 		var signer ssh.Signer
-		if strings.HasPrefix(this.params.privateKey, FILEPROTOCOL) {
-			privateKey := strings.TrimPrefix(this.params.privateKey, FILEPROTOCOL)
-			pemBytes, err = ioutil.ReadFile(privateKey)
-			if err != nil {
-				return fmt.Errorf(`Private Key File "%v": %v`, privateKey, err)
+		var e error
+		if strings.HasPrefix(key.privateKey, FILEPROTOCOL) {
+			privateKey := strings.TrimPrefix(key.privateKey, FILEPROTOCOL)
+			if pemBytes, e = ioutil.ReadFile(privateKey); e != nil {
+				errs = append(errs, fmt.Sprintf(`Private Key File "%v": %v`, privateKey, e))
+				continue
 			}
 		} else {
-			pemBytes = []byte(this.params.privateKey)
+			pemBytes = []byte(key.privateKey)
 		}
-		if this.params.usePassphrase {
-			passphraseBytes := []byte(this.params.passphrase)
-			signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, passphraseBytes)
+		if key.usePassphrase {
+			signer, e = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(key.passphrase))
 		} else {
-			signer, err = ssh.ParsePrivateKey(pemBytes)
+			signer, e = ssh.ParsePrivateKey(pemBytes)
 		}
-		if err != nil {
+		if e != nil {
+			errs = append(errs, fmt.Sprintf(`Private Key #%d: %v`, len(signers)+len(errs)+1, e))
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(errs) > 0 {
+		err = errors.New(strings.Join(errs, "; "))
+	}
+	return
+}
+
+// readSigner parses the private key read from the readers of the key, the read bytes are zeroed afterward.
+func readSigner(key *sftpKey) (signer ssh.Signer, err error) {
+	var pemBytes, passphrase []byte
+	defer func() {
+		for i := range pemBytes {
+			pemBytes[i] = 0
+		}
+		for i := range passphrase {
+			passphrase[i] = 0
+		}
+	}()
+	if pemBytes, err = io.ReadAll(key.reader); err != nil {
+		return
+	}
+	if !key.usePassphrase {
+		return ssh.ParsePrivateKey(pemBytes)
+	}
+	if passphrase, err = io.ReadAll(key.passphraseReader); err != nil {
+		return
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(pemBytes, passphrase)
+}
+
+func (this *SecureFtp) clientConfig(p *sftpParameters, signers []ssh.Signer) (config *ssh.ClientConfig, agentConn net.Conn, err error) {
+	config = &ssh.ClientConfig{
+		User:              p.user,
+		HostKeyAlgorithms: p.hostKeyAlgorithms,
+	}
+	// The unset algorithms are filled with the defaults by the SetDefaults below.
+	config.Ciphers = p.ciphers
+	config.KeyExchanges = p.keyExchanges
+	config.MACs = p.macs
+	if config.HostKeyCallback, err = this.hostKeyCallback(p); err != nil {
+		return
+	}
+
+	// The SSH client tries each authentication method only once, so all of the keys are offered by a single "publickey" method.
+	var agentClient agent.ExtendedAgent
+	if p.useAgent {
+		socket := p.agentSocket
+		if len(socket) == 0 {
+			socket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if len(socket) == 0 {
+			err = errors.New("The SSH agent is not available, SSH_AUTH_SOCK is not set.")
+			return
+		}
+		if agentConn, err = net.Dial("unix", socket); err != nil {
+			err = fmt.Errorf(`SSH Agent "%v": %v`, socket, err)
 			return
 		}
-		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+		agentClient = agent.NewClient(agentConn)
+	}
+	if len(signers) > 0 || agentClient != nil {
+		config.Auth = append(config.Auth, ssh.PublicKeysCallback(func() (keys []ssh.Signer, err error) {
+			p.logAuth("publickey")
+			keys = append(keys, signers...)
+			if agentClient != nil {
+				var agentKeys []ssh.Signer
+				if agentKeys, err = agentClient.Signers(); err != nil {
+					return
+				}
+				keys = append(keys, agentKeys...)
+			}
+			return
+		}))
 	}
 
-	if len(this.params.pass) > 0 {
-		config.Auth = append(config.Auth, ssh.Password(this.params.pass))
+	// The SSH client tries the "password" method only once, the callback takes the place of the static password.
+	if callback := p.passwordCallback; callback != nil {
+		config.Auth = append(config.Auth, ssh.PasswordCallback(func() (string, error) {
+			p.logAuth("password")
+			return callback()
+		}))
+	} else if len(p.pass) > 0 {
+		config.Auth = append(config.Auth, ssh.PasswordCallback(func() (string, error) {
+			p.logAuth("password")
+			return p.pass, nil
+		}))
+	}
+
+	if challenge := p.challenge; challenge != nil {
+		config.Auth = append(config.Auth, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			p.logAuth("keyboard-interactive")
+			return challenge(name, instruction, questions, echos)
+		}))
 	}
 
 	config.SetDefaults()
-	if ip, err = net.LookupIP(this.params.host); err != nil {
+	return
+}
+
+func (this *SecureFtp) hostKeyCallback(p *sftpParameters) (callback ssh.HostKeyCallback, err error) {
+	if p.insecure {
+		callback = ssh.InsecureIgnoreHostKey()
 		return
 	}
-	addr := fmt.Sprintf("%s:%d", ip[0], this.params.port)
-
-	if this.sshClient, err = ssh.Dial("tcp", addr, config); err != nil {
+	file := p.knownHosts
+	if len(file) == 0 {
+		var home string
+		if home, err = os.UserHomeDir(); err != nil {
+			return
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	var known ssh.HostKeyCallback
+	if known, err = knownhosts.New(file); err != nil {
+		err = fmt.Errorf(`Known Hosts File "%v": %v`, file, err)
 		return
 	}
-	if this.sftpClient, err = sftp.NewClient(this.sshClient); err != nil {
-		if e := this.sshClient.Close(); e != nil {
-			return e
+	callback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		// The rejected host key is the AuthError, it is propagated through the handshake error.
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				err = fmt.Errorf(`Unknown host key for "%v": %v %v`, hostname, key.Type(), ssh.FingerprintSHA256(key))
+			} else {
+				err = fmt.Errorf(`Host key mismatch for "%v": %v %v does not match the known_hosts file %v`, hostname, key.Type(), ssh.FingerprintSHA256(key), file)
+			}
+		} else {
+			err = fmt.Errorf(`Host key verification failed for "%v" (%v): %v`, hostname, ssh.FingerprintSHA256(key), err)
 		}
+		return &AuthError{Host: p.host, Err: err}
 	}
 	return
 }
 
+// Deprecated: the output of "ls" differs across servers and locales and requires the shell, use readDir instead.
+// list runs "ls -al" on the remote shell, the command is bounded by the list timeout or the TIMEOUT when it is unset.
 func (this *SecureFtp) list(p string) (list string, err error) {
+	p = this.resolve(p)
 	var session *ssh.Session
 	if session, err = this.sshClient.NewSession(); err != nil {
-		if e := this.quit(); e != nil {
-			return ``, e
-		}
+		return
 	}
 	defer session.Close()
 
+	// The session is closed when the command does not finish in time, the Output would wait forever otherwise.
+	timeout := this.params.listTimeout
+	if timeout <= 0 {
+		timeout, _ = time.ParseDuration(TIMEOUT)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stop := closeOnDone(ctx, session)
+
 	cmd := fmt.Sprintf("ls -al %s", p)
 	var bytes []byte
-	if bytes, err = session.Output(cmd); err != nil {
-		if e := this.quit(); e != nil {
-			return ``, e
-		}
+	bytes, err = session.Output(cmd)
+	if e := stop(); e != nil {
+		err = fmt.Errorf(`List "%v": %w`, p, e)
+	}
+	if err != nil {
+		return
 	}
 	list = string(bytes)
 	return
 }
 
-func (this *SecureFtp) download(local interface{}, remote string) (len int64, err error) {
+// run runs the cmd on the remote shell in its own session and returns the stdout and the stderr combined.
+// The non-zero exit status is the *ssh.ExitError wrapped into the err, the output is returned as well.
+func (this *SecureFtp) run(cmd string) (out []byte, err error) {
+	var session *ssh.Session
+	if session, err = this.sshClient.NewSession(); err != nil {
+		err = fmt.Errorf(`Run "%v": %w`, cmd, err)
+		return
+	}
+	defer session.Close()
+	if out, err = session.CombinedOutput(cmd); err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf(`Run "%v": exit status %d: %w`, cmd, exitErr.ExitStatus(), err)
+		} else {
+			err = fmt.Errorf(`Run "%v": %w`, cmd, err)
+		}
+	}
+	return
+}
+
+// readDirContext returns the entries of the single directory level sorted by the name, "." and ".." are excluded.
+func (this *SecureFtp) readDirContext(ctx context.Context, p string) (list []os.FileInfo, err error) {
+	p = this.resolve(p)
+	var entries []os.FileInfo
+	if entries, err = this.sftpClient.ReadDirContext(ctx, p); err != nil {
+		return
+	}
+	// The servers return the entries in the order of the file system.
+	list = make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if name := entry.Name(); name != "." && name != ".." {
+			list = append(list, entry)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return
+}
+
+func (this *SecureFtp) readDir(p string) (list []os.FileInfo, err error) {
+	return this.readDirContext(context.Background(), p)
+}
+
+func (this *SecureFtp) download(local interface{}, remote string, progress ProgressFunc) (len int64, err error) {
+	return this.downloadContext(context.Background(), local, remote, progress)
+}
+
+// downloadContext is the download aborted once the ctx is done, the remote file is closed to interrupt the pending read.
+func (this *SecureFtp) downloadContext(ctx context.Context, local interface{}, remote string, progress ProgressFunc) (len int64, err error) {
+	remote = this.resolve(remote)
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	var w io.WriteCloser
 	var r io.ReadCloser
-	var ok bool
-	if w, ok = local.(io.WriteCloser); !ok {
-		if w, err = os.Create(local.(string)); err != nil {
-			if e := this.quit(); e != nil {
-				return 0, e
-			}
+	// The remote file is opened first so that the failure to open it does not leave an empty local file behind,
+	// the partial file of a failed copy is kept for the ResumeDownload.
+	if r, err = this.sftpClient.Open(remote); err != nil {
+		err = fmt.Errorf(`Open "%v": %w`, remote, err)
+		return
+	}
+	defer r.Close()
+	if w, err = createLocal(local); err != nil {
+		return
+	}
+	// The error of the Close is reported since the written data may be lost, the len is kept as it is.
+	defer func() {
+		if e := w.Close(); e != nil && err == nil {
+			err = e
 		}
+	}()
+	var dst io.Writer = w
+	if progress != nil {
+		dst = newProgressWriter(dst, sizeOf(r), progress)
+	}
+	len, err = this.copyContext(ctx, dst, r, r)
+	if err != nil {
+		// The len bytes copied so far are flushed to the disk so that the ResumeDownload continues from them.
+		if f, ok := w.(*os.File); ok {
+			f.Sync()
+		}
+		err = fmt.Errorf(`Download "%v": %w`, remote, err)
+	}
+	this.logTransfer("download", remote, len, err)
+	return
+}
+
+// downloadTo streams the remote file into the w which is left open.
+// The copy uses the WriteTo of the *sftp.File, it reads the file with the concurrent requests instead of one packet at a time.
+func (this *SecureFtp) downloadTo(w io.Writer, remote string) (len int64, err error) {
+	remote = this.resolve(remote)
+	var r *sftp.File
+	if r, err = this.sftpClient.Open(remote); err != nil {
+		err = fmt.Errorf(`Open "%v": %w`, remote, err)
+		return
+	}
+	defer r.Close()
+	if len, err = this.copy(w, r, r); err != nil {
+		err = fmt.Errorf(`Download "%v": %w`, remote, err)
+	}
+	this.logTransfer("download", remote, len, err)
+	return
+}
+
+// readFile reads the whole remote file into the memory, the limit is the maximum size in bytes and a negative limit means unlimited.
+func (this *SecureFtp) readFile(remote string, limit int64) (data []byte, err error) {
+	remote = this.resolve(remote)
+	var r *sftp.File
+	if r, err = this.sftpClient.Open(remote); err != nil {
+		err = fmt.Errorf(`ReadFile "%v": %w`, remote, err)
+		return
+	}
+	defer r.Close()
+	buf := new(bytes.Buffer)
+	var src io.Reader = r
+	if limit >= 0 {
+		// The one more byte tells the file exceeding the limit from the file of the exact limit.
+		src = io.LimitReader(r, limit+1)
+	}
+	var len int64
+	if len, err = this.copy(buf, src, r); err != nil {
+		err = fmt.Errorf(`ReadFile "%v": %w`, remote, err)
+		return
+	}
+	if limit >= 0 && len > limit {
+		err = fmt.Errorf(`ReadFile "%v": the file exceeds the limit of %d bytes.`, remote, limit)
+		return
+	}
+	data = buf.Bytes()
+	return
+}
+
+// uploadEnsureDirs creates the missing parent directories of the remote before the upload,
+// the remote path is split by "/" whatever the separator of the local OS is.
+func (this *SecureFtp) uploadEnsureDirs(local interface{}, remote string) (len int64, err error) {
+	remote = this.resolve(remote)
+	if err = this.mkdirAll(path.Dir(remote)); err != nil {
+		return
+	}
+	return this.upload(local, remote, nil)
+}
+
+// writeFile writes the data to the remote file through the upload, so the atomic upload is honored, and applies the mode.
+func (this *SecureFtp) writeFile(remote string, data []byte, mode os.FileMode) (err error) {
+	if _, err = this.upload(io.NopCloser(bytes.NewReader(data)), remote, nil); err != nil {
+		return
+	}
+	err = this.chmod(remote, mode)
+	return
+}
+
+// resumeDownload continues the download from the current size of the local file, it returns the length copied by this call.
+// The local file larger than the remote one is reported as the error and left as it is.
+func (this *SecureFtp) resumeDownload(local string, remote string) (len int64, err error) {
+	remote = this.resolve(remote)
+	var w *os.File
+	if w, err = os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return
 	}
 	defer w.Close()
+	var info os.FileInfo
+	if info, err = w.Stat(); err != nil {
+		return
+	}
+	var r *sftp.File
 	if r, err = this.sftpClient.Open(remote); err != nil {
-		if e := this.quit(); e != nil {
-			return 0, e
-		}
+		err = fmt.Errorf(`Open "%v": %w`, remote, err)
+		return
 	}
 	defer r.Close()
-	if len, err = io.Copy(w, r); err != nil {
-		if e := this.quit(); e != nil {
-			return 0, e
-		}
+	// The local file larger than the remote one is not a prefix of it, seeking past the end would report the success.
+	var remoteInfo os.FileInfo
+	if remoteInfo, err = r.Stat(); err != nil {
+		err = fmt.Errorf(`Stat "%v": %w`, remote, err)
+		return
 	}
+	if info.Size() > remoteInfo.Size() {
+		err = fmt.Errorf(`Resume "%v": the local file "%v" has %d bytes, more than the %d bytes of the remote file.`,
+			remote, local, info.Size(), remoteInfo.Size())
+		return
+	}
+	if _, err = r.Seek(info.Size(), io.SeekStart); err != nil {
+		err = fmt.Errorf(`Seek "%v" to %d: %w`, remote, info.Size(), err)
+		return
+	}
+	if len, err = this.copy(w, r, r); err != nil {
+		err = fmt.Errorf(`Download "%v": %w`, remote, err)
+		return
+	}
+	err = w.Close()
 	return
 }
 
-func (this *SecureFtp) upload(local interface{}, remote string) (len int64, err error) {
+func (this *SecureFtp) upload(local interface{}, remote string, progress ProgressFunc) (len int64, err error) {
+	return this.uploadContext(context.Background(), local, remote, progress)
+}
+
+// uploadContext is the upload aborted once the ctx is done, the remote file is closed to interrupt the pending write.
+func (this *SecureFtp) uploadContext(ctx context.Context, local interface{}, remote string, progress ProgressFunc) (len int64, err error) {
+	return this.uploadPerm(ctx, local, remote, progress, nil)
+}
+
+// uploadPerm is the upload applying the mode to the remote file right after it is opened and before any data is written,
+// the remote file has the mode of the server default (or its previous mode) in between. The nil mode keeps the server default.
+func (this *SecureFtp) uploadPerm(ctx context.Context, local interface{}, remote string, progress ProgressFunc, mode *os.FileMode) (len int64, err error) {
+	remote = this.resolve(remote)
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	var r io.ReadCloser
-	var ok bool
-	if r, ok = local.(io.ReadCloser); !ok {
-		if r, err = os.Open(local.(string)); err != nil {
-			if e := this.quit(); e != nil {
-				return 0, e
+	if r, err = openLocal(local); err != nil {
+		return
+	}
+	defer r.Close()
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if this.params.noClobber {
+		if _, e := this.sftpClient.Lstat(remote); e == nil {
+			err = fmt.Errorf(`Upload "%v": %w`, remote, ErrExists)
+			return
+		}
+	}
+	// The atomic upload writes to the temporary file and moves it into place after the copy succeeded,
+	// so the consumers never see a partial file. The temporary file left by a failed upload is truncated.
+	target := remote
+	if this.params.atomicUpload {
+		target = remote + ".part"
+	} else if this.params.noClobber {
+		// The file created meanwhile by someone else is not truncated either.
+		flags |= os.O_EXCL
+	}
+	var w *sftp.File
+	if w, err = this.sftpClient.OpenFile(target, flags); err != nil {
+		err = fmt.Errorf(`Create "%v": %w`, target, err)
+		return
+	}
+	if mode != nil {
+		if err = w.Chmod(*mode); err != nil {
+			w.Close()
+			if this.params.atomicUpload {
+				this.sftpClient.Remove(target)
 			}
+			err = fmt.Errorf(`Chmod "%v": %w`, target, err)
+			return
 		}
 	}
-	defer r.Close()
-	var w io.WriteCloser
-	if w, err = this.sftpClient.Create(remote); err != nil {
-		if e := this.quit(); e != nil {
-			return 0, e
+	var dst io.Writer = w
+	if progress != nil {
+		dst = newProgressWriter(dst, sizeOf(r), progress)
+	}
+	// The len is the bytes actually copied even when the copy or a later step failed.
+	len, err = this.copyContext(ctx, dst, r, w)
+	if err == nil && this.params.fsync {
+		err = this.fsync(w)
+	}
+	if e := w.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		err = fmt.Errorf(`Upload "%v": %w`, target, err)
+	}
+	if this.params.atomicUpload {
+		if err == nil && this.params.noClobber {
+			// The plain rename fails when the target exists, so the file created meanwhile is not overwritten.
+			if err = this.sftpClient.Rename(target, remote); err != nil {
+				if _, e := this.sftpClient.Lstat(remote); e == nil {
+					err = fmt.Errorf(`Upload "%v": %w`, remote, ErrExists)
+				} else {
+					err = fmt.Errorf(`Rename "%v" to "%v": %w`, target, remote, err)
+				}
+			}
+		} else if err == nil {
+			if err = this.replace(target, remote); err != nil {
+				err = fmt.Errorf(`Rename "%v" to "%v": %w`, target, remote, err)
+			}
+		}
+		if err != nil {
+			this.sftpClient.Remove(target)
 		}
 	}
-	defer w.Close()
-	if len, err = io.Copy(w, r); err != nil {
-		if e := this.quit(); e != nil {
-			return 0, e
+	this.logTransfer("upload", remote, len, err)
+
+	return
+}
+
+// appendTo copies the local onto the end of the remote file instead of truncating it, the remote file is created when missing.
+func (this *SecureFtp) appendTo(local interface{}, remote string) (len int64, err error) {
+	remote = this.resolve(remote)
+	var r io.ReadCloser
+	if r, err = openLocal(local); err != nil {
+		return
+	}
+	defer r.Close()
+	var w *sftp.File
+	if w, err = this.sftpClient.OpenFile(remote, os.O_APPEND|os.O_WRONLY|os.O_CREATE); err != nil {
+		err = fmt.Errorf(`Open "%v": %w`, remote, err)
+		return
+	}
+	// Some servers ignore the append flag and write at the offset of the request, the writes start from the end instead.
+	if _, err = w.Seek(0, io.SeekEnd); err != nil {
+		w.Close()
+		err = fmt.Errorf(`Seek "%v": %w`, remote, err)
+		return
+	}
+	// The ReadFrom of the *sftp.File is hidden, its concurrent writes may be reordered by the server in the append mode.
+	len, err = this.copy(struct{ io.Writer }{w}, r, w)
+	if e := w.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		err = fmt.Errorf(`Append "%v": %w`, remote, err)
+	}
+	return
+}
+
+// createLocal opens the local for writing, the local is either the io.WriteCloser, the io.Writer or the path of the file.
+// The io.Writer is not closed, e.g. the http.ResponseWriter.
+func createLocal(local interface{}) (w io.WriteCloser, err error) {
+	switch l := local.(type) {
+	case io.WriteCloser:
+		w = l
+	case io.Writer:
+		w = nopWriteCloser{l}
+	case string:
+		w, err = os.Create(l)
+	default:
+		err = fmt.Errorf("Unsupported local type %T, it must be the io.Writer or the path of the file.", local)
+	}
+	return
+}
+
+// openLocal opens the local for reading, the local is either the io.ReadCloser or the path of the file.
+func openLocal(local interface{}) (r io.ReadCloser, err error) {
+	switch l := local.(type) {
+	case io.ReadCloser:
+		r = l
+	case string:
+		r, err = os.Open(l)
+	default:
+		err = fmt.Errorf("Unsupported local type %T, it must be the io.ReadCloser or the path of the file.", local)
+	}
+	return
+}
+
+// replace moves the old to the new, the new is overwritten atomically when the server supports the posix-rename extension.
+func (this *SecureFtp) replace(old string, new string) (err error) {
+	if this.hasExtension("posix-rename@openssh.com") {
+		err = this.sftpClient.PosixRename(old, new)
+		return
+	}
+	if _, e := this.sftpClient.Lstat(new); e == nil {
+		if err = this.sftpClient.Remove(new); err != nil {
+			return
 		}
 	}
+	err = this.sftpClient.Rename(old, new)
+	return
+}
+
+// put copies the local file to the remote path.
+func (this *SecureFtp) put(local string, remote string) (len int64, err error) {
+	remote = this.resolve(remote)
+	defer func() {
+		this.logTransfer("upload", remote, len, err)
+	}()
+	var r *os.File
+	if r, err = os.Open(local); err != nil {
+		return
+	}
+	defer r.Close()
+	var w *sftp.File
+	if w, err = this.sftpClient.Create(remote); err != nil {
+		err = fmt.Errorf(`Create "%v": %w`, remote, err)
+		return
+	}
+	if len, err = this.copy(w, r, w); err != nil {
+		w.Close()
+		return
+	}
+	err = w.Close()
+	return
+}
+
+// get copies the remote file to the local path.
+func (this *SecureFtp) get(local string, remote string) (len int64, err error) {
+	remote = this.resolve(remote)
+	defer func() {
+		this.logTransfer("download", remote, len, err)
+	}()
+	var r *sftp.File
+	if r, err = this.sftpClient.Open(remote); err != nil {
+		err = fmt.Errorf(`Open "%v": %w`, remote, err)
+		return
+	}
+	defer r.Close()
+	var w *os.File
+	if w, err = os.Create(local); err != nil {
+		return
+	}
+	if len, err = this.copy(w, r, r); err != nil {
+		w.Close()
+		return
+	}
+	err = w.Close()
+	return
+}
+
+// resolve joins the relative remote path p to the current directory set by the chdir,
+// the separators of the local OS in the p are converted to "/" beforehand.
+func (this *SecureFtp) resolve(p string) string {
+	p = filepath.ToSlash(p)
+	if len(this.cwd) == 0 || path.IsAbs(p) {
+		return p
+	}
+	return path.Join(this.cwd, p)
+}
+
+// CleanRemote converts the separators of the local OS in the remote path p to "/" and cleans it by the path.Clean,
+// e.g. the path built by the filepath.Join on Windows. The SFTP paths are always separated by "/".
+// The remote paths given to the methods are converted the same way, without the cleaning.
+func CleanRemote(p string) string {
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// realPath returns the canonical absolute form of the p resolved by the server, the symlinks and ".." are resolved.
+func (this *SecureFtp) realPath(p string) (real string, err error) {
+	p = this.resolve(p)
+	if real, err = this.sftpClient.RealPath(p); err != nil {
+		err = fmt.Errorf(`RealPath "%v": %w`, p, err)
+	}
+	return
+}
+
+// getwd returns the current directory set by the chdir, or the initial directory of the server.
+func (this *SecureFtp) getwd() (dir string, err error) {
+	if len(this.cwd) > 0 {
+		dir = this.cwd
+		return
+	}
+	return this.sftpClient.Getwd()
+}
+
+// chdir sets the directory the subsequent relative remote paths are resolved against, it is kept over the reconnection.
+func (this *SecureFtp) chdir(p string) (err error) {
+	var dir string
+	if dir, err = this.sftpClient.RealPath(this.resolve(p)); err != nil {
+		err = fmt.Errorf(`Chdir "%v": %w`, p, err)
+		return
+	}
+	var info os.FileInfo
+	if info, err = this.sftpClient.Stat(dir); err != nil {
+		err = fmt.Errorf(`Chdir "%v": %w`, p, err)
+		return
+	}
+	if !info.IsDir() {
+		err = fmt.Errorf(`Chdir "%v": not a directory.`, p)
+		return
+	}
+	this.cwd = dir
+	return
+}
+
+func (this *SecureFtp) stat(p string) (info os.FileInfo, err error) {
+	p = this.resolve(p)
+	if info, err = this.sftpClient.Stat(p); err != nil {
+		// The *os.PathError keeps the os.IsNotExist of the error.
+		err = &os.PathError{Op: "Stat", Path: p, Err: err}
+	}
+	return
+}
+
+func (this *SecureFtp) lstat(p string) (info os.FileInfo, err error) {
+	p = this.resolve(p)
+	if info, err = this.sftpClient.Lstat(p); err != nil {
+		err = &os.PathError{Op: "Lstat", Path: p, Err: err}
+	}
+	return
+}
+
+// exists reports whether the remote path exists, the err is not nil only when it could not be determined.
+func (this *SecureFtp) exists(p string) (ok bool, err error) {
+	p = this.resolve(p)
+	if _, err = this.sftpClient.Stat(p); err == nil {
+		ok = true
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (this *SecureFtp) openFile(p string, flag int) (file *sftp.File, err error) {
+	p = this.resolve(p)
+	if file, err = this.sftpClient.OpenFile(p, flag); err != nil {
+		err = fmt.Errorf(`Open "%v": %w`, p, err)
+	}
+	return
+}
+
+// writeAt writes the data to the remote file at the off without truncating it, the file is created when it does not exist.
+func (this *SecureFtp) writeAt(remote string, data []byte, off int64) (n int, err error) {
+	remote = this.resolve(remote)
+	var w *sftp.File
+	if w, err = this.sftpClient.OpenFile(remote, os.O_WRONLY|os.O_CREATE); err != nil {
+		err = fmt.Errorf(`WriteAt "%v": %w`, remote, err)
+		return
+	}
+	n, err = w.WriteAt(data, off)
+	if e := w.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		err = fmt.Errorf(`WriteAt "%v" at %d: %w`, remote, off, err)
+	}
+	return
+}
+
+// readAt reads the len(buf) bytes of the remote file at the off, the err is io.EOF when the file ends before the buf is filled.
+func (this *SecureFtp) readAt(remote string, buf []byte, off int64) (n int, err error) {
+	remote = this.resolve(remote)
+	var r *sftp.File
+	if r, err = this.sftpClient.Open(remote); err != nil {
+		err = fmt.Errorf(`ReadAt "%v": %w`, remote, err)
+		return
+	}
+	defer r.Close()
+	// The io.EOF is returned as it is, just like the io.ReaderAt.
+	if n, err = r.ReadAt(buf, off); err != nil && err != io.EOF {
+		err = fmt.Errorf(`ReadAt "%v" at %d: %w`, remote, off, err)
+	}
+	return
+}
 
+func (this *SecureFtp) chmod(p string, mode os.FileMode) (err error) {
+	p = this.resolve(p)
+	if err = this.sftpClient.Chmod(p, mode); err != nil {
+		err = fmt.Errorf(`Chmod "%v": %w`, p, err)
+	}
+	return
+}
+
+func (this *SecureFtp) chtimes(p string, atime time.Time, mtime time.Time) (err error) {
+	p = this.resolve(p)
+	if err = this.sftpClient.Chtimes(p, atime, mtime); err != nil {
+		err = fmt.Errorf(`Chtimes "%v": %w`, p, err)
+	}
+	return
+}
+
+func (this *SecureFtp) chown(p string, uid int, gid int) (err error) {
+	p = this.resolve(p)
+	if err = this.sftpClient.Chown(p, uid, gid); err != nil && errors.Is(err, os.ErrPermission) {
+		err = fmt.Errorf(`Chown "%v" to %d:%d requires the elevated privileges: %w`, p, uid, gid, err)
+	}
+	return
+}
+
+func (this *SecureFtp) truncate(p string, size int64) (err error) {
+	p = this.resolve(p)
+	if err = this.sftpClient.Truncate(p, size); err != nil {
+		err = fmt.Errorf(`Truncate "%v": %w`, p, err)
+	}
+	return
+}
+
+func (this *SecureFtp) glob(pattern string) (matches []string, err error) {
+	pattern = this.resolve(pattern)
+	if matches, err = this.sftpClient.Glob(pattern); err != nil {
+		err = fmt.Errorf(`Glob "%v": %w`, pattern, err)
+	}
 	return
 }
 
 func (this *SecureFtp) mkdir(p string) (err error) {
+	p = this.resolve(p)
 	if err = this.sftpClient.Mkdir(p); err != nil {
-		if e := this.quit(); e != nil {
-			panic(e)
-		}
+		err = fmt.Errorf(`Mkdir "%v": %w`, p, err)
+	}
+	return
+}
+
+// mkdirMode creates the directory and applies the mode by the chmod, since the servers often ignore the mode of the mkdir.
+// The directory has the mode of the server umask until the chmod, and it is kept when the chmod failed.
+func (this *SecureFtp) mkdirMode(p string, mode os.FileMode) (err error) {
+	if err = this.mkdir(p); err != nil {
+		return
+	}
+	err = this.chmod(p, mode)
+	return
+}
+
+// mkdirAll creates the directory with all of its missing ancestors, it does nothing when the directory already exists.
+func (this *SecureFtp) mkdirAll(p string) (err error) {
+	p = this.resolve(p)
+	if err = this.sftpClient.MkdirAll(p); err != nil {
+		err = fmt.Errorf(`Mkdir "%v": %w`, p, err)
 	}
 	return
 }
 
 func (this *SecureFtp) remove(p string) (err error) {
+	p = this.resolve(p)
+	if this.params.dryRun {
+		this.logDryRun("remove", "path", p)
+		return
+	}
 	if err = this.sftpClient.Remove(p); err != nil {
-		if e := this.quit(); e != nil {
-			panic(e)
-		}
+		err = fmt.Errorf(`Remove "%v": %w`, p, err)
 	}
 	return
 }
 
 func (this *SecureFtp) rename(old, new string) (err error) {
+	old = this.resolve(old)
+	new = this.resolve(new)
+	if this.params.dryRun {
+		this.logDryRun("rename", "path", old, "to", new)
+		return
+	}
 	if err = this.sftpClient.Rename(old, new); err != nil {
-		if e := this.quit(); e != nil {
-			panic(e)
-		}
+		err = fmt.Errorf(`Rename "%v" to "%v": %w`, old, new, err)
 	}
 	return
 }
 
-func (this *SecureFtp) symlink(dest, src string) (err error) {
-	if err = this.sftpClient.Symlink(src, dest); err != nil {
-		if e := this.quit(); e != nil {
-			panic(e)
+// moveInto moves the src into the destDir keeping its base name, the destDir is created when missing.
+func (this *SecureFtp) moveInto(src string, destDir string) (err error) {
+	src = path.Clean(this.resolve(src))
+	destDir = this.resolve(destDir)
+	dst := path.Join(destDir, path.Base(src))
+	// The src already in the destDir is left as it is, some servers fail the rename of a path onto itself.
+	if dst == src {
+		return
+	}
+	if !this.params.dryRun {
+		if err = this.mkdirAll(destDir); err != nil {
+			return
 		}
 	}
+	return this.rename(src, dst)
+}
+
+// copyRemote copies the remote file src to the remote file dst, the data passes through the client
+// since the SFTP protocol has no server side copy, but nothing is written to the local disk.
+func (this *SecureFtp) copyRemote(src string, dst string) (len int64, err error) {
+	src = this.resolve(src)
+	dst = this.resolve(dst)
+	var r *sftp.File
+	if r, err = this.sftpClient.Open(src); err != nil {
+		err = fmt.Errorf(`Copy "%v": %w`, src, err)
+		return
+	}
+	defer r.Close()
+	var w *sftp.File
+	if w, err = this.sftpClient.Create(dst); err != nil {
+		err = fmt.Errorf(`Copy "%v" to "%v": %w`, src, dst, err)
+		return
+	}
+	len, err = this.copy(w, r, closers{r, w})
+	if e := w.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		err = fmt.Errorf(`Copy "%v" to "%v": %w`, src, dst, err)
+	}
+	return
+}
+
+// symlink creates the newname as a symlink to the oldname, the arguments are ordered like os.Symlink.
+func (this *SecureFtp) symlink(oldname, newname string) (err error) {
+	newname = this.resolve(newname)
+	if err = this.sftpClient.Symlink(oldname, newname); err != nil {
+		err = fmt.Errorf(`Symlink "%v" to "%v": %w`, newname, oldname, err)
+	}
+	return
+}
+
+func (this *SecureFtp) readLink(p string) (target string, err error) {
+	p = this.resolve(p)
+	if target, err = this.sftpClient.ReadLink(p); err != nil {
+		err = fmt.Errorf(`ReadLink "%v": %w`, p, err)
+	}
+	return
+}
+
+// ping makes a cheap round trip to the server, it fails when the connection is dead.
+func (this *SecureFtp) ping() (err error) {
+	if this.sftpClient == nil {
+		err = &ConnectionError{Host: this.params.host, Err: errors.New("Connection is not established.")}
+		return
+	}
+	// Whatever fails the round trip, the connection is not usable.
+	if _, err = this.sftpClient.Getwd(); err != nil {
+		err = &ConnectionError{Host: this.params.host, Err: fmt.Errorf("Ping: %w", err)}
+	}
+	return
+}
+
+// reconnect closes the current connection, whatever its state, and connects again with the stored parameters.
+func (this *SecureFtp) reconnect() (err error) {
+	this.quit()
+	err = this.connect()
 	return
 }
 
 func (this *SecureFtp) quit() (err error) {
-	if err = this.sftpClient.Close(); err != nil {
+	if this.stopKeepAlive != nil {
+		close(this.stopKeepAlive)
+		this.stopKeepAlive = nil
+	}
+	if this.sftpClient == nil {
 		return
 	}
-	if err = this.sshClient.Close(); err != nil {
+	// Each connection is closed even when the previous one failed to, the SSH connection would leak otherwise.
+	err = errors.Join(this.sftpClient.Close(), this.sshClient.Close(), this.closeJumpHost())
+	return
+}
+
+// closeJumpHost closes the connection to the jump host, it must be called after the connection to the final host is closed.
+func (this *SecureFtp) closeJumpHost() (err error) {
+	if this.jumpClient == nil {
 		return
 	}
+	err = this.jumpClient.Close()
+	this.jumpClient = nil
 	return
 }
@@ -0,0 +1,360 @@
+package sftps
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestConnectNonRoutableTimeout(t *testing.T) {
+	// The 192.0.2.1 belongs to the TEST-NET-1 which is reserved for the documentation and never routed.
+	client, err := NewClient(Config{
+		Host:                     "192.0.2.1",
+		User:                     "user",
+		Password:                 "pass",
+		InsecureSkipHostKeyCheck: true,
+		Timeout:                  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = client.Connect(); err == nil {
+		t.Fatal("Connect to the non-routable address succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Connect took %v, the timeout is 200ms", elapsed)
+	}
+	if !IsConnection(err) {
+		t.Fatalf("Connect error %v is not the ConnectionError", err)
+	}
+}
+
+func TestConnectHandshakeTimeout(t *testing.T) {
+	// The listener accepts the connection but never answers the SSH handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	client, err := NewClient(Config{
+		Host:                     "127.0.0.1",
+		Port:                     addr.Port,
+		User:                     "user",
+		Password:                 "pass",
+		InsecureSkipHostKeyCheck: true,
+		Timeout:                  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = client.Connect(); err == nil {
+		t.Fatal("Connect to the silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Connect took %v, the timeout is 200ms", elapsed)
+	}
+}
+
+func TestUploadNoClobberAtomic(t *testing.T) {
+	client := newTestClient(t, newTestServer(t), WithNoClobber(true), WithAtomicUpload())
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.txt")
+	if err := os.WriteFile(local, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(dir, "remote.txt")
+	if _, _, err := client.Upload(local, filepath.ToSlash(remote)); err != nil {
+		t.Fatalf("Upload to the missing file = %v", err)
+	}
+	if err := os.WriteFile(remote, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Upload(local, filepath.ToSlash(remote)); !errors.Is(err, ErrExists) {
+		t.Fatalf("Upload over the existing file = %v, want the ErrExists", err)
+	}
+	if got, err := os.ReadFile(remote); err != nil || string(got) != "old" {
+		t.Fatalf("the existing file was overwritten with %q, %v", got, err)
+	}
+	if _, err := os.Stat(remote + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("the temporary file was left behind, %v", err)
+	}
+
+	// The temporary file left by a crashed upload does not block the upload of the missing file.
+	fresh := filepath.Join(dir, "fresh.txt")
+	if err := os.WriteFile(fresh+".part", []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Upload(local, filepath.ToSlash(fresh)); err != nil {
+		t.Fatalf("Upload over the stale temporary file = %v", err)
+	}
+	if got, err := os.ReadFile(fresh); err != nil || string(got) != "new" {
+		t.Fatalf("the uploaded file = %q, %v", got, err)
+	}
+}
+
+func TestResumeDownload(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	data := []byte(strings.Repeat("resume", 1000))
+	remote := filepath.Join(dir, "remote.txt")
+	if err := os.WriteFile(remote, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(dir, "local.txt")
+	if err := os.WriteFile(local, data[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := client.ResumeDownload(local, filepath.ToSlash(remote))
+	if err != nil || n != int64(len(data)-1000) {
+		t.Fatalf("ResumeDownload = %d, %v", n, err)
+	}
+	if got, err := os.ReadFile(local); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("resumed content differs, %v", err)
+	}
+
+	// The local file larger than the remote one is refused and kept as it is.
+	larger := append(append([]byte{}, data...), "extra"...)
+	if err := os.WriteFile(local, larger, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ResumeDownload(local, filepath.ToSlash(remote)); err == nil {
+		t.Fatal("ResumeDownload of the larger local file succeeded")
+	}
+	if got, err := os.ReadFile(local); err != nil || !bytes.Equal(got, larger) {
+		t.Fatalf("the larger local file was modified, %v", err)
+	}
+}
+
+func TestUploadDownload(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	local := filepath.Join(dir, "local.txt")
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.ToSlash(filepath.Join(dir, "remote.txt"))
+	if _, n, err := client.Upload(local, remote); err != nil || n != int64(len(data)) {
+		t.Fatalf("Upload = %d, %v", n, err)
+	}
+	downloaded := filepath.Join(dir, "downloaded.txt")
+	if _, n, err := client.Download(downloaded, remote); err != nil || n != int64(len(data)) {
+		t.Fatalf("Download = %d, %v", n, err)
+	}
+	if got, err := os.ReadFile(downloaded); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("downloaded content differs, %v", err)
+	}
+}
+
+func TestUploadCountOnError(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.ToSlash(filepath.Join(t.TempDir(), "remote.txt"))
+	// The len is the bytes copied before the stream failed.
+	_, n, err := client.Upload(io.NopCloser(&flakyReader{halves: []string{"abc", "def"}}), remote)
+	if err == nil || n != 3 {
+		t.Fatalf("Upload of the failing stream = %d, %v, want 3 bytes and the error", n, err)
+	}
+	if data, err := client.ReadFile(remote); err != nil || string(data) != "abc" {
+		t.Fatalf("the remote file after the failed Upload = %q, %v", data, err)
+	}
+}
+
+// backslashes is the `dir\sub\file` converted to the remote path, only the separator of Windows is converted
+// since the backslash is a regular character of the names on the other systems.
+var backslashes = `dir\sub\file`
+
+func init() {
+	if filepath.Separator == '\\' {
+		backslashes = "dir/sub/file"
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		cwd  string
+		p    string
+		want string
+	}{
+		{"", "a/b.txt", "a/b.txt"},
+		{"", "/a/b.txt", "/a/b.txt"},
+		{"/home/user", "a/b.txt", "/home/user/a/b.txt"},
+		{"/home/user", "/a/b.txt", "/a/b.txt"},
+		{"/home/user", "../b.txt", "/home/b.txt"},
+		{"/home/user", `dir\sub\file`, "/home/user/" + backslashes},
+	}
+	for _, test := range tests {
+		client := &SecureFtp{cwd: test.cwd}
+		if got := client.resolve(test.p); got != test.want {
+			t.Errorf("resolve(%q) with cwd %q = %q, want %q", test.p, test.cwd, got, test.want)
+		}
+	}
+}
+
+func TestChdir(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := filepath.ToSlash(t.TempDir())
+	if err := client.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err := client.Getwd(); err != nil || wd != dir {
+		t.Fatalf("Getwd after the Chdir = %q, %v, want %q", wd, err, dir)
+	}
+	// The relative path is resolved against the current directory.
+	if err := client.WriteFile("a.txt", []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(filepath.FromSlash(dir), "a.txt")); err != nil || string(data) != "a" {
+		t.Fatalf("the relative file = %q, %v", data, err)
+	}
+}
+
+func TestListTimeout(t *testing.T) {
+	// The command never finishes, it hangs until the test ends.
+	hang := make(chan struct{})
+	t.Cleanup(func() {
+		close(hang)
+	})
+	port := newExecTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		<-hang
+		return 0
+	})
+	// The connect is not bounded, the command is bounded by its own timeout.
+	client := newTestClient(t, port, WithListTimeout(200*time.Millisecond))
+	start := time.Now()
+	if _, _, err := client.List("."); err == nil {
+		t.Fatal("List of the hanging command succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("List took %v, the list timeout is 200ms", elapsed)
+	}
+}
+
+func TestCleanRemote(t *testing.T) {
+	want := "a/c.txt"
+	if got := CleanRemote(filepath.Join("a", "b", "..", "c.txt")); got != want {
+		t.Errorf("CleanRemote = %q, want %q", got, want)
+	}
+	if got := CleanRemote("/a//b/"); got != "/a/b" {
+		t.Errorf("CleanRemote = %q, want %q", got, "/a/b")
+	}
+	if got := CleanRemote(`dir\sub\file`); got != backslashes {
+		t.Errorf("CleanRemote = %q, want %q", got, backslashes)
+	}
+}
+
+func TestDownloadMissingLeavesNoLocalFile(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.txt")
+	if _, _, err := client.Download(local, filepath.ToSlash(filepath.Join(dir, "missing.txt"))); err == nil {
+		t.Fatal("Download of the missing file succeeded")
+	}
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Fatalf("the local file was created, %v", err)
+	}
+}
+
+func TestDownloadPartialOnError(t *testing.T) {
+	handlers := sftp.InMemHandler()
+	// The reads beyond the first 64KB fail, the download stops in the middle of the file.
+	const after = 64 * 1024
+	handlers.FileGet = failingReader{FileReader: handlers.FileGet, after: after}
+	client := newTestClient(t, newHandlersTestServer(t, handlers))
+	data := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	if err := client.WriteFile("/remote.bin", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	local := filepath.Join(t.TempDir(), "local.bin")
+	_, n, err := client.Download(local, "/remote.bin")
+	if err == nil {
+		t.Fatal("Download of the failing file succeeded")
+	}
+	if n <= 0 || n > after {
+		t.Fatalf("Download = %d, want the bytes before the failure", n)
+	}
+	// The local file keeps exactly the bytes reported, so the ResumeDownload can continue from there.
+	got, err := os.ReadFile(local)
+	if err != nil || int64(len(got)) != n || !bytes.Equal(got, data[:n]) {
+		t.Fatalf("the partial local file has %d bytes, want %d, %v", len(got), n, err)
+	}
+}
+
+func TestStallTimeout(t *testing.T) {
+	handlers := sftp.InMemHandler()
+	stall := make(chan struct{})
+	handlers.FileGet = failingReader{FileReader: handlers.FileGet, after: 64 * 1024, stall: stall}
+	client := newTestClient(t, newHandlersTestServer(t, handlers), WithStallTimeout(200*time.Millisecond))
+	// The hung reads are released before the client quits.
+	t.Cleanup(func() {
+		close(stall)
+	})
+	if err := client.WriteFile("/remote.bin", bytes.Repeat([]byte("x"), 256*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	transfers := []struct {
+		name     string
+		transfer func() error
+	}{
+		{"Download", func() error {
+			_, _, err := client.Download(filepath.Join(dir, "download.bin"), "/remote.bin")
+			return err
+		}},
+		{"ResumeDownload", func() error {
+			_, err := client.ResumeDownload(filepath.Join(dir, "resume.bin"), "/remote.bin")
+			return err
+		}},
+		{"DownloadTo", func() error {
+			_, err := client.DownloadTo(io.Discard, "/remote.bin")
+			return err
+		}},
+		{"ReadFile", func() error {
+			_, err := client.ReadFile("/remote.bin")
+			return err
+		}},
+		{"Copy", func() error {
+			_, err := client.Copy("/remote.bin", "/copy.bin")
+			return err
+		}},
+		{"DownloadConcurrent", func() error {
+			_, err := client.DownloadConcurrent("/remote.bin", filepath.Join(dir, "concurrent.bin"), 4)
+			return err
+		}},
+	}
+	for _, tt := range transfers {
+		start := time.Now()
+		if err := tt.transfer(); !errors.Is(err, ErrStalled) {
+			t.Errorf("%s of the hung file = %v, want the ErrStalled", tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s took %v to notice the stall", tt.name, elapsed)
+		}
+		// The stall closed the connection.
+		if _, err := client.Reconnect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
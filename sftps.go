@@ -1,7 +1,17 @@
 package sftps
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
 )
 
 type FtpResponse struct {
@@ -10,6 +20,8 @@ type FtpResponse struct {
 	msg     string
 }
 
+// Sftps is the client of the FTP, FTPS and SFTP servers. The Connect, Quit, List, Mkdir, Rmdir, Rename, Upload and Download
+// serve all the protocols, the other methods are only supported by the SFTP protocol and return the error for the others.
 type Sftps struct {
 	state     int
 	protocol  int
@@ -79,6 +91,50 @@ func (this *Sftps) Connect() (res []*FtpResponse, err error) {
 	return
 }
 
+// ConnectContext connects to the SFTP server, the ctx bounds the DNS resolution and the TCP/SSH handshake.
+// It returns the error of the ctx when the ctx is cancelled or its deadline is exceeded.
+func (this *Sftps) ConnectContext(ctx context.Context) (res []*FtpResponse, err error) {
+	if this.protocol != SFTP {
+		err = errors.New("ConnectContext is only supported by the SFTP protocol.")
+		return
+	}
+	if err = this.recv.(*SecureFtp).connectContext(ctx); err != nil {
+		return
+	}
+	this.state = ONLINE
+	return
+}
+
+// ConnectOverConn connects to the SFTP server over the conn dialed by the caller, e.g. a tunnel or a unix socket proxy,
+// instead of dialing the host. The host of the parameters is still used to verify the host key,
+// and the Reconnect or the reconnection policy dials the host as usual.
+func (this *Sftps) ConnectOverConn(conn net.Conn) (err error) {
+	if this.protocol != SFTP {
+		err = errors.New("ConnectOverConn is only supported by the SFTP protocol.")
+		return
+	}
+	if err = this.recv.(*SecureFtp).connectOverConn(conn); err != nil {
+		return
+	}
+	this.state = ONLINE
+	return
+}
+
+// Reconnect closes the current connection, whatever its state, and connects again with the stored parameters.
+func (this *Sftps) Reconnect() (res []*FtpResponse, err error) {
+	if this.protocol == SFTP {
+		if err = this.recv.(*SecureFtp).reconnect(); err != nil {
+			this.state = OFFLINE
+			return
+		}
+		this.state = ONLINE
+		return
+	}
+	this.Quit()
+	res, err = this.Connect()
+	return
+}
+
 func (this *Sftps) Quit() (res *FtpResponse, err error) {
 	if this.protocol == FTP || this.protocol == FTPS {
 		if res, err = this.recv.(*Ftp).quit(); err != nil {
@@ -93,11 +149,64 @@ func (this *Sftps) Quit() (res *FtpResponse, err error) {
 	return
 }
 
+// secure runs fn with the receiver of the SFTP protocol, the connection is closed afterward when the keepalive is disabled.
+// The lost connection is reported as the ConnectionError, the other errors of fn are returned as they are.
+func (this *Sftps) secure(fn func(sftp *SecureFtp) error) (err error) {
+	return this.secureReplay(fn, true)
+}
+
+// secureOnce is the secure never retrying the fn by the reconnect policy, for the operations which can not be repeated
+// after they failed midway, e.g. the append would write the data twice and the consumed stream is not read again.
+func (this *Sftps) secureOnce(fn func(sftp *SecureFtp) error) (err error) {
+	return this.secureReplay(fn, false)
+}
+
+// replayable reports whether the transfer from or to the local can be repeated from the start, i.e. the local is a path.
+func replayable(local interface{}) bool {
+	_, ok := local.(string)
+	return ok
+}
+
+// secureReplay is the secure retrying the fn by the reconnect policy only when the replay is true.
+func (this *Sftps) secureReplay(fn func(sftp *SecureFtp) error, replay bool) (err error) {
+	if this.state == OFFLINE {
+		err = errors.New("Connection is not established.")
+		return
+	}
+	sftp, ok := this.recv.(*SecureFtp)
+	if !ok {
+		err = errors.New("The operation is only supported by the SFTP protocol.")
+		return
+	}
+	err = fn(sftp)
+	if policy := sftp.params.reconnect; policy != nil && replay {
+		for attempt := 0; attempt < policy.MaxAttempts && err != nil && policy.retryable(err); attempt++ {
+			time.Sleep(policy.backoff(attempt))
+			if e := sftp.reconnect(); e != nil {
+				err = fmt.Errorf("%w (Reconnect: %v)", err, e)
+				continue
+			}
+			err = fn(sftp)
+		}
+	}
+	err = classify(sftp.params.host, err)
+	if !this.keepalive {
+		if e := sftp.quit(); e != nil && err == nil {
+			err = e
+		}
+		this.state = OFFLINE
+	}
+	return
+}
+
 func (this *Sftps) StringToEntities(raw string) (ents []*Entity, err error) {
 	ents, err = stringToEntities(raw)
 	return
 }
 
+// List returns the listing of the baseDir as the text, the response of the LIST command for the FTP and FTPS
+// and the output of "ls -al" in the remote shell for the SFTP, bounded by the WithListTimeout.
+// For the SFTP prefer the ListFileInfo which does not depend on the shell.
 func (this *Sftps) List(baseDir string) (res []*FtpResponse, list string, err error) {
 
 	if this.state == OFFLINE {
@@ -121,23 +230,30 @@ func (this *Sftps) List(baseDir string) (res []*FtpResponse, list string, err er
 			res = append(res, r)
 		}
 	} else if this.protocol == SFTP {
-		var sftp *SecureFtp
-		if recv, ok := this.recv.(*SecureFtp); ok {
-			sftp = recv
-		}
-		if list, err = sftp.list(baseDir); err != nil {
+		err = this.secure(func(sftp *SecureFtp) (e error) {
+			list, e = sftp.list(baseDir)
 			return
-		}
-
-		if !this.keepalive {
-			if err = sftp.quit(); err != nil {
-				return
-			}
-		}
+		})
 	}
 	return
 }
 
+// ListFileInfo is the ReadDir, it returns the entries of the directory with their name, size, mode and modification time.
+// It works even when the remote account has no shell.
+func (this *Sftps) ListFileInfo(p string) (list []os.FileInfo, err error) {
+	return this.ReadDir(p)
+}
+
+// ReadDir returns the entries of the single directory level sorted by the name, "." and ".." are excluded.
+// It does not depend on the remote shell.
+func (this *Sftps) ReadDir(p string) (list []os.FileInfo, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		list, e = sftp.readDir(p)
+		return
+	})
+	return
+}
+
 func (this *Sftps) Mkdir(p string) (res []*FtpResponse, err error) {
 	if this.state == OFFLINE {
 		err = errors.New("Connection is not established.")
@@ -162,19 +278,9 @@ func (this *Sftps) Mkdir(p string) (res []*FtpResponse, err error) {
 			res = append(res, r)
 		}
 	} else if this.protocol == SFTP {
-		var sftp *SecureFtp
-		if recv, ok := this.recv.(*SecureFtp); ok {
-			sftp = recv
-		}
-		if err = sftp.mkdir(p); err != nil {
-			return
-		}
-
-		if !this.keepalive {
-			if err = sftp.quit(); err != nil {
-				return
-			}
-		}
+		err = this.secure(func(sftp *SecureFtp) error {
+			return sftp.mkdir(p)
+		})
 	}
 	return
 }
@@ -203,18 +309,9 @@ func (this *Sftps) Rmdir(p string) (res []*FtpResponse, err error) {
 			res = append(res, r)
 		}
 	} else if this.protocol == SFTP {
-		var sftp *SecureFtp
-		if recv, ok := this.recv.(*SecureFtp); ok {
-			sftp = recv
-		}
-		if err = sftp.remove(p); err != nil {
-			return
-		}
-		if !this.keepalive {
-			if err = sftp.quit(); err != nil {
-				return
-			}
-		}
+		err = this.secure(func(sftp *SecureFtp) error {
+			return sftp.remove(p)
+		})
 	}
 	return
 }
@@ -241,23 +338,19 @@ func (this *Sftps) Rename(old string, new string) (res []*FtpResponse, err error
 			res = append(res, r)
 		}
 	} else if this.protocol == SFTP {
-		var sftp *SecureFtp
-		if recv, ok := this.recv.(*SecureFtp); ok {
-			sftp = recv
-		}
-		if err = sftp.rename(old, new); err != nil {
-			return
-		}
-		if !this.keepalive {
-			if err = sftp.quit(); err != nil {
-				return
-			}
-		}
+		err = this.secure(func(sftp *SecureFtp) error {
+			return sftp.rename(old, new)
+		})
 	}
 	return
 }
 
-//Upload parameter's explain. local is the local path for the file, whether remote.
+// Upload parameter's explain. local is the local path for the file or the io.ReadCloser, whether remote.
+// The other types of the local are reported as the error.
+// The existing remote file is truncated.
+//
+// Deprecated: for the SFTP protocol use Overwrite or Append, which name what happens to the existing remote file.
+// Upload stays the way to store the file over FTP and FTPS.
 func (this *Sftps) Upload(local interface{}, remote string) (res []*FtpResponse, len int64, err error) {
 	if this.state == OFFLINE {
 		err = errors.New("Connection is not established")
@@ -281,22 +374,21 @@ func (this *Sftps) Upload(local interface{}, remote string) (res []*FtpResponse,
 			res = append(res, r)
 		}
 	} else if this.protocol == SFTP {
-		var sftp *SecureFtp
-		if recv, ok := this.recv.(*SecureFtp); ok {
-			sftp = recv
-		}
-		if len, err = sftp.upload(local, remote); err != nil {
+		err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+			len, e = sftp.upload(local, remote, nil)
 			return
-		}
-		if !this.keepalive {
-			if err = sftp.quit(); err != nil {
-				return
-			}
-		}
+		}, replayable(local))
 	}
 	return
 }
 
+// Download copies the remote file to the local, the local is the path of the file or the io.WriteCloser.
+// For the SFTP protocol it may also be the io.Writer (e.g. the bytes.Buffer or the http.ResponseWriter, which is not closed).
+// The other types are reported as the error.
+// When the SFTP copy fails midway the len is the count of the bytes written to the local along with the error,
+// the local file is kept with exactly those bytes so that the ResumeDownload can be called right away.
+// With the retry policy a failed download to the path of a file is restarted from the beginning, the len is the one
+// of the last attempt. The download to an io.Writer is not retried.
 func (this *Sftps) Download(local interface{}, remote string) (res []*FtpResponse, len int64, err error) {
 	if this.state == OFFLINE {
 		err = errors.New("Connection is not established")
@@ -320,18 +412,626 @@ func (this *Sftps) Download(local interface{}, remote string) (res []*FtpRespons
 			res = append(res, r)
 		}
 	} else if this.protocol == SFTP {
-		var sftp *SecureFtp
-		if recv, ok := this.recv.(*SecureFtp); ok {
-			sftp = recv
-		}
-		if len, err = sftp.download(local, remote); err != nil {
+		err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+			len, e = sftp.download(local, remote, nil)
 			return
+		}, replayable(local))
+	}
+	return
+}
+
+// UploadDir uploads the local directory tree to the remoteRoot, the structure of the directories is recreated remotely.
+// The opts may be nil.
+func (this *Sftps) UploadDir(localRoot string, remoteRoot string, opts *DirOptions) (summary *DirSummary, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		summary, e = sftp.uploadDir(localRoot, remoteRoot, opts)
+		return
+	})
+	return
+}
+
+// DownloadDir downloads the remote directory tree to the localRoot, the structure of the directories is recreated locally.
+// The opts may be nil.
+func (this *Sftps) DownloadDir(remoteRoot string, localRoot string, opts *DirOptions) (summary *DirSummary, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		summary, e = sftp.downloadDir(remoteRoot, localRoot, opts)
+		return
+	})
+	return
+}
+
+// ResumeDownload continues the interrupted download from the current size of the local file,
+// it returns the length copied by this call. The local file left by the failed Download or DownloadContext
+// is a valid starting point. The local file larger than the remote one fails since it is not a part of the remote file.
+func (this *Sftps) ResumeDownload(local string, remote string) (len int64, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.resumeDownload(local, remote)
+		return
+	})
+	return
+}
+
+// UploadWithProgress is the Upload that calls the progress as the bytes flow,
+// the total is -1 when the size of the local is unknown.
+func (this *Sftps) UploadWithProgress(local interface{}, remote string, progress ProgressFunc) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.upload(local, remote, progress)
+		return
+	}, replayable(local))
+	return
+}
+
+// DownloadWithProgress is the Download that calls the progress as the bytes flow.
+func (this *Sftps) DownloadWithProgress(local interface{}, remote string, progress ProgressFunc) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.download(local, remote, progress)
+		return
+	}, replayable(local))
+	return
+}
+
+// Stat returns the information of the remote file, the symlink is followed.
+// os.IsNotExist reports whether the file does not exist.
+func (this *Sftps) Stat(p string) (info os.FileInfo, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		info, e = sftp.stat(p)
+		return
+	})
+	return
+}
+
+// Lstat returns the information of the remote file, the symlink is not followed.
+// os.IsNotExist reports whether the file does not exist.
+func (this *Sftps) Lstat(p string) (info os.FileInfo, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		info, e = sftp.lstat(p)
+		return
+	})
+	return
+}
+
+// Exists reports whether the remote path exists, it returns (false, nil) when the path is missing
+// and a non-nil error only for the genuine failures such as permission denied or connection loss.
+func (this *Sftps) Exists(p string) (ok bool, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		ok, e = sftp.exists(p)
+		return
+	})
+	return
+}
+
+// IsDir reports whether the remote path is a directory, following the symlinks.
+// A missing path is the error satisfying IsNotFound, not (false, nil), so it is told from a regular file.
+func (this *Sftps) IsDir(p string) (ok bool, err error) {
+	var info os.FileInfo
+	if info, err = this.Stat(p); err != nil {
+		return
+	}
+	ok = info.IsDir()
+	return
+}
+
+// Chmod changes the mode of the remote file, the permission bits and the setuid, setgid and sticky bits
+// of the mode are translated to the POSIX bits of the SFTP protocol.
+func (this *Sftps) Chmod(p string, mode os.FileMode) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.chmod(p, mode)
+	})
+	return
+}
+
+// Chtimes changes the access and modification times of the remote file.
+// The times are sent as the seconds since the Unix epoch, so the location of the time.Time does not matter
+// (the same instant is stored whatever the timezone of the client or the server) and the sub-second part is truncated.
+func (this *Sftps) Chtimes(p string, atime time.Time, mtime time.Time) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.chtimes(p, atime, mtime)
+	})
+	return
+}
+
+// Chown changes the owner and the group of the remote file, it usually requires the elevated privileges on the server.
+// errors.Is(err, os.ErrPermission) reports whether the permission is denied.
+func (this *Sftps) Chown(p string, uid int, gid int) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.chown(p, uid, gid)
+	})
+	return
+}
+
+// Walk calls the fn for each file or directory of the remote tree rooted at the root, including the root,
+// in the same manner as filepath.Walk. The symlinks are reported as themselves and not followed, see the WalkWithOptions.
+func (this *Sftps) Walk(root string, fn filepath.WalkFunc) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.walk(root, fn)
+	})
+	return
+}
+
+// WalkWithOptions is the Walk controlled by the opts, e.g. following the symlinks.
+func (this *Sftps) WalkWithOptions(root string, opts WalkOptions, fn filepath.WalkFunc) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.walkOptions(context.Background(), root, opts, fn)
+	})
+	return
+}
+
+// Glob returns the remote paths matching the pattern, the syntax is the same as path.Match (e.g. "*", "?" and "[a-z]").
+func (this *Sftps) Glob(pattern string) (matches []string, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		matches, e = sftp.glob(pattern)
+		return
+	})
+	return
+}
+
+// MkdirMode creates the remote directory and then applies the mode, e.g. 0700 where the umask of the server gives 0755.
+// It is not atomic, the directory briefly has the mode of the umask and keeps it when the Chmod failed.
+func (this *Sftps) MkdirMode(p string, mode os.FileMode) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.mkdirMode(p, mode)
+	})
+	return
+}
+
+// MoveInto moves the remote src into the destDir keeping its base name, creating the destDir when missing.
+// It does nothing when the src is already in the destDir.
+func (this *Sftps) MoveInto(src string, destDir string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.moveInto(src, destDir)
+	})
+	return
+}
+
+// MkdirAll creates the remote directory with all of its missing ancestors in the same manner as os.MkdirAll.
+func (this *Sftps) MkdirAll(p string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.mkdirAll(p)
+	})
+	return
+}
+
+// RemoveAll removes the remote path and all of its contents in the same manner as os.RemoveAll,
+// the symlinks are not followed.
+func (this *Sftps) RemoveAll(p string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.removeAll(p)
+	})
+	return
+}
+
+// ReadLink returns the target of the remote symlink.
+func (this *Sftps) ReadLink(p string) (target string, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		target, e = sftp.readLink(p)
+		return
+	})
+	return
+}
+
+// Symlink creates the remote newname as a symlink to the oldname, the arguments are ordered like os.Symlink:
+// the oldname is the target and the newname is the path of the link.
+func (this *Sftps) Symlink(oldname string, newname string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.symlink(oldname, newname)
+	})
+	return
+}
+
+// Truncate changes the size of the remote file, it shrinks the file or extends it with zeros where the server supports it.
+func (this *Sftps) Truncate(p string, size int64) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.truncate(p, size)
+	})
+	return
+}
+
+// Overwrite copies the local to the remote file, the existing remote file is truncated first and created when missing.
+// The local is either the io.ReadCloser or the path of the file.
+func (this *Sftps) Overwrite(local interface{}, remote string) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.upload(local, remote, nil)
+		return
+	}, replayable(local))
+	return
+}
+
+// Append copies the local onto the end of the remote file rather than truncating it, the remote file is created when missing.
+// The local is either the io.ReadCloser or the path of the file. It is never retried by the reconnect policy
+// since the retry would append the data twice.
+func (this *Sftps) Append(local interface{}, remote string) (len int64, err error) {
+	err = this.secureOnce(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.appendTo(local, remote)
+		return
+	})
+	return
+}
+
+// SFTPClient returns the underlying SFTP client for the capabilities not wrapped by this package, or nil for the FTP protocols.
+// Operating on it directly bypasses the wrapper: the keepalive, the reconnect policy and the error handling are not applied,
+// and the returned client is stale after Reconnect.
+func (this *Sftps) SFTPClient() *sftp.Client {
+	if recv, ok := this.recv.(*SecureFtp); ok {
+		return recv.sftpClient
+	}
+	return nil
+}
+
+// SSHClient returns the underlying SSH client, or nil for the FTP protocols. The same caveats as SFTPClient apply.
+func (this *Sftps) SSHClient() *ssh.Client {
+	if recv, ok := this.recv.(*SecureFtp); ok {
+		return recv.sshClient
+	}
+	return nil
+}
+
+// UploadFiles uploads the pairs in parallel with at most the concurrency workers over the same connection,
+// which overlaps the round trips of many small files. A failed file does not abort the others, each result reports
+// its bytes and error and the err tells how many failed.
+func (this *Sftps) UploadFiles(pairs []TransferPair, concurrency int) (results []TransferResult, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		results, e = sftp.uploadFiles(pairs, concurrency)
+		return
+	})
+	return
+}
+
+// DownloadConcurrent downloads the remote file with the chunks read in parallel and written at their offsets of the local file,
+// which uses the bandwidth better than a single stream for the large file.
+func (this *Sftps) DownloadConcurrent(remote string, local string, chunks int) (len int64, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.downloadConcurrent(remote, local, chunks)
+		return
+	})
+	return
+}
+
+// UploadVerified uploads the local and verifies the checksum of the uploaded file with the algo,
+// the *ChecksumError is returned on the mismatch. It requires WithVerifyByRedownload.
+func (this *Sftps) UploadVerified(local string, remote string, algo HashAlgo) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.uploadVerified(local, remote, algo)
+	})
+	return
+}
+
+// Size returns the size of the remote file, errors.Is(err, os.ErrNotExist) reports whether the file does not exist.
+func (this *Sftps) Size(p string) (size int64, err error) {
+	var info os.FileInfo
+	if info, err = this.Stat(p); err != nil {
+		return
+	}
+	size = info.Size()
+	return
+}
+
+// ServerExtensions returns the SFTP protocol extensions advertised by the server with their versions, e.g. "posix-rename@openssh.com",
+// or nil for the FTP protocols and before the connection is established. Only the extensions known by this package are listed.
+func (this *Sftps) ServerExtensions() map[string]string {
+	if recv, ok := this.recv.(*SecureFtp); ok && this.state == ONLINE {
+		return recv.serverExtensions()
+	}
+	return nil
+}
+
+// ServerVersion returns the SSH version of the server, e.g. "SSH-2.0-OpenSSH_8.9",
+// or an empty string for the FTP protocols and before the connection is established.
+func (this *Sftps) ServerVersion() string {
+	if recv, ok := this.recv.(*SecureFtp); ok && this.state == ONLINE {
+		return string(recv.sshClient.ServerVersion())
+	}
+	return ""
+}
+
+// PosixRename renames the remote old to the new with the posix-rename extension, which replaces the existing new atomically.
+// It returns the error when the server does not advertise the extension.
+func (this *Sftps) PosixRename(old string, new string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.posixRename(old, new)
+	})
+	return
+}
+
+// Link creates the remote newname as a hard link to the oldname with the hardlink extension, the arguments are ordered like os.Link.
+// It returns the error when the server does not advertise the extension.
+func (this *Sftps) Link(oldname string, newname string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.link(oldname, newname)
+	})
+	return
+}
+
+// StatVFS returns the statistics of the remote filesystem containing the p, e.g. the available bytes are Frsize * Bavail.
+// It returns the error when the server does not advertise the statvfs extension.
+func (this *Sftps) StatVFS(p string) (stat *sftp.StatVFS, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		stat, e = sftp.statVFS(p)
+		return
+	})
+	return
+}
+
+// UploadReader uploads the data read from the r until io.EOF, the r may be a non-seekable stream of an unknown length
+// such as a pipe and is not closed. It is never retried by the reconnect policy since the data consumed by the failed attempt
+// can not be read again.
+func (this *Sftps) UploadReader(r io.Reader, remote string) (len int64, err error) {
+	err = this.secureOnce(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.upload(io.NopCloser(r), remote, nil)
+		return
+	})
+	return
+}
+
+// OpenFile opens the remote file for the random access, the returned file implements io.ReaderAt, io.WriterAt and io.Seeker
+// and must be closed by the caller. The flag is the combination of os.O_RDONLY, os.O_WRONLY or os.O_RDWR with
+// os.O_APPEND, os.O_CREATE, os.O_EXCL and os.O_TRUNC, the same as os.OpenFile.
+// The keepalive must be enabled since the file is used after the call.
+func (this *Sftps) OpenFile(p string, flag int) (file *sftp.File, err error) {
+	if !this.keepalive {
+		err = errors.New("OpenFile requires the keepalive, the connection would be closed before the file is used.")
+		return
+	}
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		file, e = sftp.openFile(p, flag)
+		return
+	})
+	return
+}
+
+// UploadContext is the Upload aborted once the ctx is done, the copy is checked between the chunks
+// and the remote file is closed so that a stuck write returns promptly.
+func (this *Sftps) UploadContext(ctx context.Context, local interface{}, remote string) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.uploadContext(ctx, local, remote, nil)
+		return
+	}, replayable(local))
+	return
+}
+
+// DownloadContext is the Download aborted once the ctx is done, the copy is checked between the chunks
+// and the remote file is closed so that a stuck read returns promptly. Like the Download, the len of the aborted copy
+// is the count of the bytes kept in the local file.
+func (this *Sftps) DownloadContext(ctx context.Context, local interface{}, remote string) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.downloadContext(ctx, local, remote, nil)
+		return
+	}, replayable(local))
+	return
+}
+
+// ListContext is the ListFileInfo aborted once the ctx is done.
+func (this *Sftps) ListContext(ctx context.Context, p string) (list []os.FileInfo, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		list, e = sftp.readDirContext(ctx, p)
+		return
+	})
+	return
+}
+
+// WalkContext is the Walk stopped with the error of the ctx once the ctx is done.
+func (this *Sftps) WalkContext(ctx context.Context, root string, fn filepath.WalkFunc) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.walkContext(ctx, root, fn)
+	})
+	return
+}
+
+// DownloadTo streams the remote file into the w without an intermediate file, e.g. a gzip.Writer or an http.ResponseWriter.
+// The w is not closed. It is never retried by the reconnect policy since the w already received a part of the data.
+func (this *Sftps) DownloadTo(w io.Writer, remote string) (len int64, err error) {
+	err = this.secureOnce(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.downloadTo(w, remote)
+		return
+	})
+	return
+}
+
+// UploadFrom is the UploadReader, the remote file is filled from the r by its ReadFrom
+// which pipelines the writes when the concurrent writes are enabled. The r is not closed.
+func (this *Sftps) UploadFrom(r io.Reader, remote string) (len int64, err error) {
+	return this.UploadReader(r, remote)
+}
+
+// Copy copies the remote file src to the remote path dst over the single SFTP connection.
+// The SFTP protocol has no server side copy, so the data still passes through the client, but it never touches the local disk.
+func (this *Sftps) Copy(src string, dst string) (len int64, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.copyRemote(src, dst)
+		return
+	})
+	return
+}
+
+// Sync uploads the files of the localDir which are missing in the remoteDir or differ in the size or the modification time,
+// the others are skipped. The opts.Policy replaces that comparison for the existing remote files. The remote files absent locally are deleted only with the opts.Delete.
+func (this *Sftps) Sync(localDir string, remoteDir string, opts SyncOptions) (report SyncReport, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		report, e = sftp.sync(localDir, remoteDir, opts)
+		return
+	})
+	return
+}
+
+// ReadFile returns the whole content of the remote file, use the ReadFileLimit unless the file is known to be small.
+func (this *Sftps) ReadFile(remote string) (data []byte, err error) {
+	return this.ReadFileLimit(remote, -1)
+}
+
+// ReadFileLimit returns the whole content of the remote file, it fails without returning the data
+// when the file is larger than the limit in bytes. A negative limit means unlimited.
+func (this *Sftps) ReadFileLimit(remote string, limit int64) (data []byte, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		data, e = sftp.readFile(remote, limit)
+		return
+	})
+	return
+}
+
+// WriteFile creates or truncates the remote file with the data and applies the mode, the file is replaced atomically
+// when the atomic upload is enabled. The mode is applied after the file is in place.
+func (this *Sftps) WriteFile(remote string, data []byte, mode os.FileMode) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.writeFile(remote, data, mode)
+	})
+	return
+}
+
+// Getwd returns the current remote directory, which is the login directory of the server until the Chdir is called.
+func (this *Sftps) Getwd() (dir string, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		dir, e = sftp.getwd()
+		return
+	})
+	return
+}
+
+// Chdir changes the current remote directory, the relative remote paths of the subsequent operations are resolved against it.
+// The directory is tracked by the client since the SFTP protocol has no such state.
+func (this *Sftps) Chdir(p string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.chdir(p)
+	})
+	return
+}
+
+// RealPath returns the canonical absolute path of the p on the server, two paths point to the same file when their real paths are equal.
+func (this *Sftps) RealPath(p string) (real string, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		real, e = sftp.realPath(p)
+		return
+	})
+	return
+}
+
+// Ping reports whether the connection is alive by a cheap round trip, it neither reconnects nor closes the connection
+// so the pooled connections can be validated. The err of the connection not established or lost is the ConnectionError.
+func (this *Sftps) Ping() (err error) {
+	sftp, ok := this.recv.(*SecureFtp)
+	if !ok {
+		err = errors.New("The operation is only supported by the SFTP protocol.")
+		return
+	}
+	if this.state == OFFLINE {
+		err = &ConnectionError{Host: sftp.params.host, Err: errors.New("Connection is not established.")}
+		return
+	}
+	err = sftp.ping()
+	return
+}
+
+// UploadMode is the Upload creating the remote file with the mode instead of the umask of the server, e.g. 0600 for a private key.
+// The mode is applied right after the file is created and before any data is written, so only the empty file
+// has the default mode (or its previous mode when it existed) in the meantime.
+func (this *Sftps) UploadMode(local interface{}, remote string, mode os.FileMode) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.uploadPerm(context.Background(), local, remote, nil, &mode)
+		return
+	}, replayable(local))
+	return
+}
+
+// UploadEnsureDirs is the Upload creating the missing parent directories of the remote path first.
+func (this *Sftps) UploadEnsureDirs(local interface{}, remote string) (len int64, err error) {
+	err = this.secureReplay(func(sftp *SecureFtp) (e error) {
+		len, e = sftp.uploadEnsureDirs(local, remote)
+		return
+	}, replayable(local))
+	return
+}
+
+// DryRun reports whether the destructive operations are only logged, see WithDryRun.
+func (this *Sftps) DryRun() bool {
+	if sftp, ok := this.recv.(*SecureFtp); ok {
+		return sftp.params.dryRun
+	}
+	return false
+}
+
+// WriteAt writes the data to the remote file at the off in bytes, the rest of the file is kept as it is.
+// The file is opened for each call, use the OpenFile to patch many ranges.
+func (this *Sftps) WriteAt(remote string, data []byte, off int64) (n int, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		n, e = sftp.writeAt(remote, data, off)
+		return
+	})
+	return
+}
+
+// ReadAt reads the len(buf) bytes of the remote file at the off in bytes, the err is io.EOF when fewer bytes are read
+// since the file ends. The file is opened for each call, use the OpenFile to read many ranges.
+func (this *Sftps) ReadAt(remote string, buf []byte, off int64) (n int, err error) {
+	// The io.EOF is kept out of the secure, it would be taken for the lost connection.
+	eof := false
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		if n, e = sftp.readAt(remote, buf, off); e == io.EOF {
+			eof, e = true, nil
 		}
-		if !this.keepalive {
-			if err = sftp.quit(); err != nil {
-				return
-			}
-		}
+		return
+	})
+	if eof && err == nil {
+		err = io.EOF
+	}
+	return
+}
+
+// StatMany stats the remote paths concurrently over the single connection so that their round trips overlap.
+// The infos are keyed by the given paths, the paths which failed are missing from the infos and their errors are in the errs.
+func (this *Sftps) StatMany(paths []string) (infos map[string]os.FileInfo, errs []error) {
+	err := this.secure(func(sftp *SecureFtp) error {
+		infos, errs = sftp.statMany(paths, STATCONCURRENCY)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
 	}
 	return
 }
+
+// UploadTar archives the localDir into the single remoteArchive while it is uploaded,
+// which saves the round trips of the many small files.
+func (this *Sftps) UploadTar(localDir string, remoteArchive string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.uploadTar(localDir, remoteArchive)
+	})
+	return
+}
+
+// DownloadUntar extracts the remoteArchive into the localDir while it is downloaded.
+// Only the regular files and the directories are extracted, the entries escaping the localDir fail the extraction.
+func (this *Sftps) DownloadUntar(remoteArchive string, localDir string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.downloadUntar(remoteArchive, localDir)
+	})
+	return
+}
+
+// ExtractRemote extracts the remoteArchive into the existing remoteDir on the server, e.g. after the UploadTar.
+// It requires the shell and the "tar" command on the server.
+func (this *Sftps) ExtractRemote(remoteArchive string, remoteDir string) (err error) {
+	err = this.secure(func(sftp *SecureFtp) error {
+		return sftp.extractRemote(remoteArchive, remoteDir)
+	})
+	return
+}
+
+// Run runs the cmd on the remote shell over the same connection and returns the stdout and the stderr combined.
+// The non-zero exit status fails with the error including the status, errors.As(err, *ssh.ExitError) retrieves it
+// and the output is still returned. It is never retried by the reconnect policy since the cmd may have run already.
+// It requires the shell on the server.
+func (this *Sftps) Run(cmd string) (out []byte, err error) {
+	err = this.secureOnce(func(sftp *SecureFtp) (e error) {
+		out, e = sftp.run(cmd)
+		return
+	})
+	return
+}
+
+// Pull downloads the files of the remoteDir which are missing in the localDir or differ in the size or the modification time,
+// the others are skipped. The opts.Policy replaces that comparison for the existing local files. The local files absent remotely are deleted only with the opts.Delete.
+func (this *Sftps) Pull(remoteDir string, localDir string, opts SyncOptions) (report SyncReport, err error) {
+	err = this.secure(func(sftp *SecureFtp) (e error) {
+		report, e = sftp.pull(remoteDir, localDir, opts)
+		return
+	})
+	return
+}
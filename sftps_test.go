@@ -0,0 +1,401 @@
+package sftps
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// flakyReader returns the data in two halves with the connection reset in between, like a stream cut by a lost connection.
+type flakyReader struct {
+	halves []string
+	failed bool
+}
+
+func (r *flakyReader) Read(p []byte) (n int, err error) {
+	if len(r.halves) == 0 {
+		return 0, io.EOF
+	}
+	if len(r.halves) == 1 && !r.failed {
+		r.failed = true
+		return 0, syscall.ECONNRESET
+	}
+	n = copy(p, r.halves[0])
+	r.halves = r.halves[1:]
+	return
+}
+
+func TestStreamUploadsAreNotRetried(t *testing.T) {
+	client := newTestClient(t, newTestServer(t), WithRetry(3, time.Millisecond))
+	dir := t.TempDir()
+
+	remote := filepath.ToSlash(filepath.Join(dir, "reader.txt"))
+	if _, err := client.UploadReader(&flakyReader{halves: []string{"abc", "def"}}, remote); err == nil {
+		t.Fatal("UploadReader of the failing stream succeeded")
+	}
+	if data, err := client.ReadFile(remote); err != nil || string(data) != "abc" {
+		t.Fatalf("the remote file after the failed UploadReader = %q, %v", data, err)
+	}
+
+	remote = filepath.ToSlash(filepath.Join(dir, "append.txt"))
+	if err := client.WriteFile(remote, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Append(io.NopCloser(&flakyReader{halves: []string{"abc", "def"}}), remote); err == nil {
+		t.Fatal("Append of the failing stream succeeded")
+	}
+	if data, err := client.ReadFile(remote); err != nil || string(data) != "0abc" {
+		t.Fatalf("the remote file after the failed Append = %q, %v", data, err)
+	}
+}
+
+// newBenchFile writes the 8MB file into the dir and returns its content and path.
+func newBenchFile(b *testing.B, dir string) (data []byte, p string) {
+	b.Helper()
+	data = bytes.Repeat([]byte("0123456789abcdef"), 512*1024)
+	p = filepath.Join(dir, "bench.bin")
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	return data, filepath.ToSlash(p)
+}
+
+func BenchmarkDownloadTo(b *testing.B) {
+	client := newTestClient(b, newTestServer(b))
+	data, remote := newBenchFile(b, b.TempDir())
+	b.Run("WriteTo", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if _, err := client.DownloadTo(io.Discard, remote); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// The plain copy hides the WriteTo of the *sftp.File, it reads one buffer at a time.
+	b.Run("Copy", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			f, err := client.SFTPClient().Open(remote)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = io.Copy(io.Discard, struct{ io.Reader }{f}); err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	})
+}
+
+func BenchmarkUploadFrom(b *testing.B) {
+	client := newTestClient(b, newTestServer(b))
+	dir := b.TempDir()
+	data, _ := newBenchFile(b, dir)
+	remote := filepath.ToSlash(filepath.Join(dir, "uploaded.bin"))
+	b.Run("ReadFrom", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if _, err := client.UploadFrom(bytes.NewReader(data), remote); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// The plain copy hides the ReadFrom of the *sftp.File, it writes one buffer at a time.
+	b.Run("Copy", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			f, err := client.SFTPClient().Create(remote)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = io.Copy(struct{ io.Writer }{f}, bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	})
+}
+
+func TestReadDir(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	for _, name := range []string{"c.txt", "a.txt", "b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := client.ListFileInfo(filepath.ToSlash(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range list {
+		names = append(names, info.Name())
+	}
+	if got, want := strings.Join(names, ","), "a.txt,b,c.txt"; got != want {
+		t.Fatalf("ListFileInfo = %v, want %v", got, want)
+	}
+}
+
+func TestExists(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "a.txt")
+	if ok, err := client.Exists(filepath.ToSlash(remote)); ok || err != nil {
+		t.Fatalf("Exists of the missing file = %v, %v", ok, err)
+	}
+	if err := os.WriteFile(remote, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := client.Exists(filepath.ToSlash(remote)); !ok || err != nil {
+		t.Fatalf("Exists = %v, %v", ok, err)
+	}
+
+	// The denied access is the genuine failure, the file may exist or not.
+	handlers := sftp.InMemHandler()
+	handlers.FileList = deniedLister{handlers.FileList}
+	denied := newTestClient(t, newHandlersTestServer(t, handlers))
+	if ok, err := denied.Exists("/denied"); ok || !IsPermission(err) {
+		t.Fatalf("Exists of the denied file = %v, %v", ok, err)
+	}
+}
+
+func TestUploadWithProgress(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("x"), 100000)
+	local := filepath.Join(dir, "local.txt")
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var transferred, total int64
+	progress := func(n int64, size int64) {
+		transferred, total = n, size
+	}
+	if _, err := client.UploadWithProgress(local, filepath.ToSlash(filepath.Join(dir, "remote.txt")), progress); err != nil {
+		t.Fatal(err)
+	}
+	if transferred != int64(len(data)) || total != int64(len(data)) {
+		t.Fatalf("the last progress = %d of %d, want %d", transferred, total, len(data))
+	}
+}
+
+func TestChmod(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.ToSlash(filepath.Join(t.TempDir(), "script.sh"))
+	if err := client.WriteFile(remote, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Chmod(remote, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := client.Stat(remote); err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("Stat after the Chmod = %v, %v", info, err)
+	}
+}
+
+func TestChtimes(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.ToSlash(filepath.Join(dir, "a.txt"))
+	if err := client.WriteFile(remote, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := client.Chtimes(remote, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := client.Stat(remote); err != nil || !info.ModTime().Equal(mtime) {
+		t.Fatalf("Stat after the Chtimes = %v, %v", info, err)
+	}
+	missing := filepath.ToSlash(filepath.Join(dir, "missing.txt"))
+	if err := client.Chtimes(missing, mtime, mtime); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("Chtimes of the missing file = %v, want the error naming the path", err)
+	}
+}
+
+func TestChown(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.ToSlash(filepath.Join(t.TempDir(), "a.txt"))
+	if err := client.WriteFile(remote, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Only the root may give the file away, the others keep their own ids.
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 1234, 5678
+	}
+	if err := client.Chown(remote, uid, gid); err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.Stat(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat, ok := info.Sys().(*sftp.FileStat); !ok || stat.UID != uint32(uid) || stat.GID != uint32(gid) {
+		t.Fatalf("the owner after the Chown = %#v, want %d:%d", info.Sys(), uid, gid)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", "b.csv", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matches, err := client.Glob(filepath.ToSlash(filepath.Join(dir, "*.csv")))
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("Glob = %v, %v", matches, err)
+	}
+	bad := filepath.ToSlash(filepath.Join(dir, "["))
+	if _, err = client.Glob(bad); !errors.Is(err, path.ErrBadPattern) || !strings.Contains(err.Error(), bad) {
+		t.Fatalf("Glob of the bad pattern = %v, want the path.ErrBadPattern naming the pattern", err)
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.ToSlash(filepath.Join(dir, "a.txt"))
+	if err := client.WriteFile(remote, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The oldname is the target and the newname is the link, like the os.Symlink.
+	link := filepath.ToSlash(filepath.Join(dir, "link"))
+	if err := client.Symlink(remote, link); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := client.ReadLink(link); err != nil || target != remote {
+		t.Fatalf("ReadLink = %q, %v, want %q", target, err, remote)
+	}
+	if data, err := client.ReadFile(link); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile through the link = %q, %v", data, err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.ToSlash(filepath.Join(t.TempDir(), "a.txt"))
+	if err := client.WriteFile(remote, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Truncate(remote, 5); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := client.ReadFile(remote); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile after the Truncate = %q, %v", data, err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.ToSlash(filepath.Join(t.TempDir(), "a.txt"))
+	// The missing file is created.
+	if n, err := client.Append(io.NopCloser(strings.NewReader("hello")), remote); err != nil || n != 5 {
+		t.Fatalf("Append to the missing file = %d, %v", n, err)
+	}
+	if n, err := client.Append(io.NopCloser(strings.NewReader(" world")), remote); err != nil || n != 6 {
+		t.Fatalf("Append = %d, %v", n, err)
+	}
+	if data, err := client.ReadFile(remote); err != nil || string(data) != "hello world" {
+		t.Fatalf("ReadFile after the Append = %q, %v", data, err)
+	}
+}
+
+func TestSize(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.ToSlash(filepath.Join(dir, "a.txt"))
+	if err := client.WriteFile(remote, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := client.Size(remote); err != nil || size != 5 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+	if _, err := client.Size(filepath.ToSlash(filepath.Join(dir, "missing.txt"))); !IsNotFound(err) {
+		t.Fatalf("Size of the missing file = %v, want the not found error", err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.ToSlash(filepath.Join(dir, "a.txt"))
+	if err := client.WriteFile(remote, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.ToSlash(filepath.Join(dir, "link.txt"))
+	if err := client.Link(remote, link); err != nil {
+		t.Fatal(err)
+	}
+	// Both names share the content, the write through one is read through the other.
+	if _, err := client.Append(io.NopCloser(strings.NewReader("b")), link); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := client.ReadFile(remote); err != nil || string(data) != "ab" {
+		t.Fatalf("ReadFile of the original after the write to the link = %q, %v", data, err)
+	}
+	info, err := client.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("Lstat of the hard link = %v, %v", info, err)
+	}
+}
+
+func TestUploadReader(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.ToSlash(filepath.Join(t.TempDir(), "a.txt"))
+	// The reader without the size is streamed until its EOF.
+	data := strings.Repeat("stream", 10000)
+	if n, err := client.UploadReader(struct{ io.Reader }{strings.NewReader(data)}, remote); err != nil || n != int64(len(data)) {
+		t.Fatalf("UploadReader = %d, %v", n, err)
+	}
+	if got, err := client.ReadFile(remote); err != nil || string(got) != data {
+		t.Fatalf("the uploaded content differs, %v", err)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	remote := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(remote, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := client.ReadFile(filepath.ToSlash(remote)); err != nil || string(data) != "0123456789" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+	if _, err := client.ReadFileLimit(filepath.ToSlash(remote), 5); err == nil {
+		t.Fatal("ReadFileLimit of the file over the limit succeeded")
+	}
+	if data, err := client.ReadFileLimit(filepath.ToSlash(remote), 10); err != nil || string(data) != "0123456789" {
+		t.Fatalf("ReadFileLimit of the file at the limit = %q, %v", data, err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	if err := client.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Quit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(); !IsConnection(err) {
+		t.Fatalf("Ping after the Quit = %v, want the ConnectionError", err)
+	}
+	// The closed session is reported the same even when the state is still online.
+	session := client.recv.(*SecureFtp)
+	if err := session.ping(); !IsConnection(err) {
+		t.Fatalf("ping of the closed session = %v, want the ConnectionError", err)
+	}
+}
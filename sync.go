@@ -0,0 +1,268 @@
+package sftps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Decision is what the Sync or the Pull does with a source file whose destination file already exists.
+type Decision int
+
+const (
+	// DecisionSkip leaves the destination file as it is.
+	DecisionSkip Decision = iota
+	// DecisionOverwrite replaces the destination file by the source file.
+	DecisionOverwrite
+	// DecisionRename moves the destination file aside to its name suffixed with the current time (e.g. "a.txt.20260102150405")
+	// and then transfers the source file, the previous content is kept.
+	DecisionRename
+)
+
+// SyncPolicy decides what to do with the src file whose dst file already exists, the src is the local file for the Sync
+// and the remote file for the Pull.
+type SyncPolicy func(src os.FileInfo, dst os.FileInfo) Decision
+
+// SizeModTimePolicy is the default SyncPolicy, it skips the dst having the same size and modification time as the src
+// and overwrites it otherwise. The SFTP carries the modification time in seconds, so the times are compared in seconds.
+func SizeModTimePolicy(src os.FileInfo, dst os.FileInfo) Decision {
+	if src.Size() == dst.Size() && src.ModTime().Unix() == dst.ModTime().Unix() {
+		return DecisionSkip
+	}
+	return DecisionOverwrite
+}
+
+// SyncOptions controls the Sync of a local directory to a remote directory and the Pull of the reverse.
+type SyncOptions struct {
+	// Delete removes the files and directories of the destination which do not exist in the source.
+	Delete bool
+	// Policy decides what to do with each existing destination file, the SizeModTimePolicy is used when nil.
+	// The missing destination files are always transferred.
+	Policy SyncPolicy
+}
+
+func (opts SyncOptions) decide(src os.FileInfo, dst os.FileInfo) Decision {
+	if opts.Policy != nil {
+		return opts.Policy(src, dst)
+	}
+	return SizeModTimePolicy(src, dst)
+}
+
+// SyncReport lists the paths of the destination handled by the Sync or the Pull,
+// i.e. the remote paths for the Sync and the local paths for the Pull.
+// The Renamed lists the paths the destination files decided with the DecisionRename were moved to.
+type SyncReport struct {
+	Uploaded   []string
+	Downloaded []string
+	Skipped    []string
+	Renamed    []string
+	Deleted    []string
+	Bytes      int64
+}
+
+func (r SyncReport) String() string {
+	return fmt.Sprintf("%d uploaded, %d downloaded, %d skipped, %d renamed, %d deleted, %d bytes",
+		len(r.Uploaded), len(r.Downloaded), len(r.Skipped), len(r.Renamed), len(r.Deleted), r.Bytes)
+}
+
+// asideName returns the path the file p decided with the DecisionRename is moved to.
+func asideName(p string) string {
+	return p + "." + time.Now().Format("20060102150405")
+}
+
+// sync uploads the files of the localDir missing in the remoteDir or those the policy of the opts decides to transfer,
+// by default the ones differing in the size or the modification time.
+// The modification time of the uploaded file is set to the local one so that the next sync skips it.
+// With the dry run nothing is changed, the report lists the files which would be uploaded and deleted.
+func (this *SecureFtp) sync(localDir string, remoteDir string, opts SyncOptions) (report SyncReport, err error) {
+	remoteDir = this.resolve(remoteDir)
+	remotes := map[string]os.FileInfo{}
+	err = this.walk(remoteDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == remoteDir && errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if p != remoteDir {
+			remotes[p] = info
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf(`Sync "%v": %w`, remoteDir, err)
+		return
+	}
+
+	locals := map[string]bool{}
+	err = filepath.Walk(localDir, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, local)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(remoteDir, filepath.ToSlash(rel))
+		locals[remote] = true
+
+		if info.IsDir() {
+			if _, ok := remotes[remote]; !ok && !this.params.dryRun {
+				return this.mkdirAll(remote)
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if r, ok := remotes[remote]; ok && r.Mode().IsRegular() {
+			switch opts.decide(info, r) {
+			case DecisionSkip:
+				report.Skipped = append(report.Skipped, remote)
+				return nil
+			case DecisionRename:
+				aside := asideName(remote)
+				if err := this.rename(remote, aside); err != nil {
+					return err
+				}
+				report.Renamed = append(report.Renamed, aside)
+			}
+		}
+		if this.params.dryRun {
+			this.logDryRun("upload", "local", local, "remote", remote)
+			report.Uploaded = append(report.Uploaded, remote)
+			return nil
+		}
+		len, err := this.put(local, remote)
+		report.Bytes += len
+		if err != nil {
+			return fmt.Errorf(`Upload "%v" to "%v": %w`, local, remote, err)
+		}
+		if err = this.chtimes(remote, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+		report.Uploaded = append(report.Uploaded, remote)
+		return nil
+	})
+	if err != nil || !opts.Delete {
+		return
+	}
+
+	// The entries of a removed directory are removed along with it, so only the topmost absent path is removed.
+	paths := make([]string, 0, len(remotes))
+	for p := range remotes {
+		if !locals[p] && locals[path.Dir(p)] {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err = this.removeAll(p); err != nil {
+			return
+		}
+		report.Deleted = append(report.Deleted, p)
+	}
+	return
+}
+
+// pull downloads the files of the remoteDir missing in the localDir or those the policy of the opts decides to transfer,
+// by default the ones differing in the size or the modification time.
+// The modification time of the downloaded file is set to the remote one so that the next pull skips it.
+// With the dry run nothing is changed, the report lists the files which would be downloaded and deleted.
+func (this *SecureFtp) pull(remoteDir string, localDir string, opts SyncOptions) (report SyncReport, err error) {
+	remoteDir = path.Clean(this.resolve(remoteDir))
+	localDir = filepath.Clean(localDir)
+	locals := map[string]os.FileInfo{}
+	err = filepath.Walk(localDir, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			if local == localDir && errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if local != localDir {
+			locals[local] = info
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf(`Pull "%v": %w`, localDir, err)
+		return
+	}
+
+	remotes := map[string]bool{}
+	err = this.walk(remoteDir, func(remote string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(remote, remoteDir), "/")
+		local := filepath.Join(localDir, filepath.FromSlash(rel))
+		remotes[local] = true
+
+		if info.IsDir() {
+			if this.params.dryRun {
+				return nil
+			}
+			return os.MkdirAll(local, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if l, ok := locals[local]; ok && l.Mode().IsRegular() {
+			switch opts.decide(info, l) {
+			case DecisionSkip:
+				report.Skipped = append(report.Skipped, local)
+				return nil
+			case DecisionRename:
+				aside := asideName(local)
+				if this.params.dryRun {
+					this.logDryRun("rename", "path", local, "to", aside)
+				} else if err := os.Rename(local, aside); err != nil {
+					return err
+				}
+				report.Renamed = append(report.Renamed, aside)
+			}
+		}
+		if this.params.dryRun {
+			this.logDryRun("download", "remote", remote, "local", local)
+			report.Downloaded = append(report.Downloaded, local)
+			return nil
+		}
+		len, err := this.get(local, remote)
+		report.Bytes += len
+		if err != nil {
+			return fmt.Errorf(`Download "%v" to "%v": %w`, remote, local, err)
+		}
+		if err = os.Chtimes(local, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+		report.Downloaded = append(report.Downloaded, local)
+		return nil
+	})
+	if err != nil || !opts.Delete {
+		return
+	}
+
+	// The entries of a removed directory are removed along with it, so only the topmost absent path is removed.
+	paths := make([]string, 0, len(locals))
+	for l := range locals {
+		if !remotes[l] && remotes[filepath.Dir(l)] {
+			paths = append(paths, l)
+		}
+	}
+	sort.Strings(paths)
+	for _, l := range paths {
+		if this.params.dryRun {
+			this.logDryRun("remove", "path", l)
+		} else if err = os.RemoveAll(l); err != nil {
+			return
+		}
+		report.Deleted = append(report.Deleted, l)
+	}
+	return
+}
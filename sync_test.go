@@ -0,0 +1,146 @@
+package sftps
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testFileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+func (i testFileInfo) Name() string       { return "file" }
+func (i testFileInfo) Size() int64        { return i.size }
+func (i testFileInfo) Mode() fs.FileMode  { return 0644 }
+func (i testFileInfo) ModTime() time.Time { return i.modTime }
+func (i testFileInfo) IsDir() bool        { return false }
+func (i testFileInfo) Sys() interface{}   { return nil }
+
+// writeTestTree writes the files keyed by their slash separated path under the root.
+func writeTestTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSizeModTimePolicy(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		src  testFileInfo
+		dst  testFileInfo
+		want Decision
+	}{
+		{testFileInfo{10, now}, testFileInfo{10, now}, DecisionSkip},
+		// The SFTP carries the seconds only, the fraction is ignored.
+		{testFileInfo{10, now.Add(500 * time.Millisecond)}, testFileInfo{10, now}, DecisionSkip},
+		{testFileInfo{10, now}, testFileInfo{11, now}, DecisionOverwrite},
+		{testFileInfo{10, now.Add(time.Second)}, testFileInfo{10, now}, DecisionOverwrite},
+	}
+	for _, test := range tests {
+		if got := SizeModTimePolicy(test.src, test.dst); got != test.want {
+			t.Errorf("SizeModTimePolicy(%v, %v) = %v, want %v", test.src, test.dst, got, test.want)
+		}
+	}
+}
+
+func TestAsideName(t *testing.T) {
+	aside := asideName("/data/a.txt")
+	suffix := strings.TrimPrefix(aside, "/data/a.txt.")
+	if suffix == aside {
+		t.Fatalf("asideName = %q, want the %q prefix", aside, "/data/a.txt.")
+	}
+	if _, err := time.Parse("20060102150405", suffix); err != nil {
+		t.Errorf("asideName suffix %q is not the timestamp, %v", suffix, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	remote := filepath.ToSlash(filepath.Join(dir, "remote"))
+	writeTestTree(t, src, map[string]string{"a.txt": "a", "sub/b.txt": "bb"})
+
+	report, err := client.Sync(src, remote, SyncOptions{})
+	if err != nil || len(report.Uploaded) != 2 {
+		t.Fatalf("Sync = %v, %v", report, err)
+	}
+	if report, err = client.Sync(src, remote, SyncOptions{}); err != nil || len(report.Skipped) != 2 {
+		t.Fatalf("Sync of the unchanged files = %v, %v", report, err)
+	}
+	// The changed size is uploaded again.
+	writeTestTree(t, src, map[string]string{"a.txt": "aa"})
+	if report, err = client.Sync(src, remote, SyncOptions{}); err != nil || len(report.Uploaded) != 1 || len(report.Skipped) != 1 {
+		t.Fatalf("Sync of the changed file = %v, %v", report, err)
+	}
+}
+
+func TestPull(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote")
+	dst := filepath.Join(dir, "dst")
+	writeTestTree(t, remote, map[string]string{"a.txt": "a", "sub/b.txt": "bb"})
+
+	report, err := client.Pull(filepath.ToSlash(remote), dst, SyncOptions{})
+	if err != nil || len(report.Downloaded) != 2 {
+		t.Fatalf("Pull = %v, %v", report, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sub", "b.txt")); err != nil || string(data) != "bb" {
+		t.Fatalf("pulled content = %q, %v", data, err)
+	}
+
+	// The extraneous local file is only deleted with the Delete.
+	extra := filepath.Join(dst, "extra.txt")
+	if err = os.WriteFile(extra, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = client.Pull(filepath.ToSlash(remote), dst, SyncOptions{}); err != nil || len(report.Deleted) != 0 {
+		t.Fatalf("Pull without the Delete = %v, %v", report, err)
+	}
+	if _, err = os.Stat(extra); err != nil {
+		t.Fatalf("the extraneous file was deleted without the Delete, %v", err)
+	}
+	if report, err = client.Pull(filepath.ToSlash(remote), dst, SyncOptions{Delete: true}); err != nil || len(report.Deleted) != 1 {
+		t.Fatalf("Pull with the Delete = %v, %v", report, err)
+	}
+	if _, err = os.Stat(extra); !os.IsNotExist(err) {
+		t.Fatalf("the extraneous file was kept, %v", err)
+	}
+}
+
+func TestPullRename(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote")
+	dst := filepath.Join(dir, "dst")
+	writeTestTree(t, remote, map[string]string{"a.txt": "new"})
+	writeTestTree(t, dst, map[string]string{"a.txt": "old"})
+
+	// The DecisionRename keeps the previous content of the destination aside.
+	rename := func(src os.FileInfo, dst os.FileInfo) Decision {
+		return DecisionRename
+	}
+	report, err := client.Pull(filepath.ToSlash(remote), dst, SyncOptions{Policy: rename})
+	if err != nil || len(report.Renamed) != 1 || len(report.Downloaded) != 1 {
+		t.Fatalf("Pull with the DecisionRename = %v, %v", report, err)
+	}
+	if data, err := os.ReadFile(report.Renamed[0]); err != nil || string(data) != "old" {
+		t.Fatalf("the renamed file = %q, %v, want the previous content", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "a.txt")); err != nil || string(data) != "new" {
+		t.Fatalf("pulled content = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,141 @@
+package sftps
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// uploadTar streams the tar archive of the localDir into the remoteArchive, the archive is never written to the local disk.
+// The regular files, the directories and the symlinks (as the symlinks) are archived with the paths relative to the localDir.
+func (this *SecureFtp) uploadTar(localDir string, remoteArchive string) (err error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, localDir))
+	}()
+	_, err = this.upload(pr, remoteArchive, nil)
+	// The writer is unblocked when the upload failed before reading the whole archive.
+	pr.CloseWithError(io.ErrClosedPipe)
+	return
+}
+
+func writeTar(w io.Writer, localDir string) (err error) {
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(localDir, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, local)
+		if err != nil || rel == "." {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(local); err != nil {
+				return err
+			}
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return
+	}
+	return tw.Close()
+}
+
+// downloadUntar extracts the remoteArchive into the localDir while it is downloaded.
+// Only the regular files and the directories are extracted, the entries escaping the localDir are refused.
+func (this *SecureFtp) downloadUntar(remoteArchive string, localDir string) (err error) {
+	remoteArchive = this.resolve(remoteArchive)
+	var r io.ReadCloser
+	if r, err = this.sftpClient.Open(remoteArchive); err != nil {
+		err = fmt.Errorf(`Open "%v": %w`, remoteArchive, err)
+		return
+	}
+	defer r.Close()
+	tr := tar.NewReader(r)
+	for {
+		var header *tar.Header
+		if header, err = tr.Next(); err == io.EOF {
+			err = nil
+			return
+		} else if err != nil {
+			err = fmt.Errorf(`Untar "%v": %w`, remoteArchive, err)
+			return
+		}
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			err = fmt.Errorf(`Untar "%v": the entry "%v" is outside of the directory.`, remoteArchive, header.Name)
+			return
+		}
+		local := filepath.Join(localDir, filepath.FromSlash(name))
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(local, mode|0700)
+		case tar.TypeReg:
+			err = untarFile(tr, local, mode)
+		}
+		if err != nil {
+			err = fmt.Errorf(`Untar "%v": %w`, remoteArchive, err)
+			return
+		}
+	}
+}
+
+func untarFile(r io.Reader, local string, mode os.FileMode) (err error) {
+	if err = os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return
+	}
+	var w *os.File
+	if w, err = os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode); err != nil {
+		return
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return
+	}
+	return w.Close()
+}
+
+// extractRemote extracts the remoteArchive into the remoteDir by the "tar" command of the remote shell.
+func (this *SecureFtp) extractRemote(remoteArchive string, remoteDir string) (err error) {
+	remoteArchive = this.resolve(remoteArchive)
+	remoteDir = this.resolve(remoteDir)
+	cmd := fmt.Sprintf("tar -xf %s -C %s", shellQuote(remoteArchive), shellQuote(remoteDir))
+	if out, e := this.run(cmd); e != nil {
+		err = fmt.Errorf(`Extract "%v" to "%v": %w (%v)`, remoteArchive, remoteDir, e, strings.TrimSpace(string(out)))
+	}
+	return
+}
+
+// shellQuote quotes the s for the POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,63 @@
+package sftps
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	if got, want := shellQuote("it's"), `'it'\''s'`; got != want {
+		t.Errorf("shellQuote = %s, want %s", got, want)
+	}
+}
+
+func TestDownloadUntarRefusesEscapingEntries(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	for _, name := range []string{"../escaped.txt", "/abs.txt", "a/../../escaped.txt"} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte("x"))
+		tw.Close()
+		archive := filepath.Join(dir, "archive.tar")
+		if err := os.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out := filepath.Join(dir, "out")
+		if err := client.DownloadUntar(filepath.ToSlash(archive), out); err == nil {
+			t.Errorf("DownloadUntar of the entry %q succeeded", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "escaped.txt")); !os.IsNotExist(err) {
+			t.Fatalf("the entry %q was extracted outside of the directory", name)
+		}
+	}
+}
+
+func TestUploadTarDownloadUntar(t *testing.T) {
+	client := newTestClient(t, newTestServer(t))
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.ToSlash(filepath.Join(dir, "archive.tar"))
+	if err := client.UploadTar(src, archive); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := client.DownloadUntar(archive, out); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "sub", "a.txt")); err != nil || string(data) != "a" {
+		t.Fatalf("extracted content = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,96 @@
+package sftps
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// HashAlgo is the algorithm of the checksum verifying the transfer.
+type HashAlgo string
+
+const (
+	MD5    HashAlgo = "md5"
+	SHA256 HashAlgo = "sha256"
+)
+
+func (algo HashAlgo) new() (h hash.Hash, err error) {
+	switch algo {
+	case MD5:
+		h = md5.New()
+	case SHA256:
+		h = sha256.New()
+	default:
+		err = fmt.Errorf(`Unsupported hash algorithm "%v".`, algo)
+	}
+	return
+}
+
+// ChecksumError is returned when the checksum of the uploaded file does not match the local one, the upload may be retried.
+type ChecksumError struct {
+	Remote string
+	Algo   HashAlgo
+	Local  string
+	Actual string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf(`Checksum mismatch for "%v": %v of the local is %v, but the remote is %v.`, e.Remote, e.Algo, e.Local, e.Actual)
+}
+
+// WithVerifyByRedownload verifies the UploadVerified by downloading the uploaded file and hashing it again.
+func WithVerifyByRedownload(verify bool) Option {
+	return func(param *sftpParameters) {
+		param.verifyByRedownload = verify
+	}
+}
+
+// uploadVerified uploads the local while hashing it and compares the checksum with the uploaded file.
+// The "check-file" extension can not be requested through github.com/pkg/sftp, so the uploaded file is downloaded
+// and hashed again, which must be enabled by WithVerifyByRedownload.
+func (this *SecureFtp) uploadVerified(local string, remote string, algo HashAlgo) (err error) {
+	remote = this.resolve(remote)
+	if !this.params.verifyByRedownload {
+		err = errors.New("The server-side checksum is not available, enable WithVerifyByRedownload to verify the upload.")
+		return
+	}
+	var localHash, remoteHash hash.Hash
+	if localHash, err = algo.new(); err != nil {
+		return
+	}
+	if remoteHash, err = algo.new(); err != nil {
+		return
+	}
+
+	var f *os.File
+	if f, err = os.Open(local); err != nil {
+		return
+	}
+	r := struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(f, localHash), f}
+	if _, err = this.upload(r, remote, nil); err != nil {
+		return
+	}
+
+	if _, err = this.download(remoteHash, remote, nil); err != nil {
+		return
+	}
+	expected := hex.EncodeToString(localHash.Sum(nil))
+	actual := hex.EncodeToString(remoteHash.Sum(nil))
+	if expected != actual {
+		err = &ChecksumError{
+			Remote: remote,
+			Algo:   algo,
+			Local:  expected,
+			Actual: actual,
+		}
+	}
+	return
+}